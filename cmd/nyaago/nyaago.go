@@ -22,9 +22,14 @@ const (
 	exitLoggerError
 	exitServerError
 	exitAPIError
+	exitReplayError
 )
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "replay" {
+		os.Exit(runReplay(os.Args[2:]))
+	}
+
 	var cfgPath string
 	flag.StringVar(&cfgPath, "config", "config.json", "path to the configuration file")
 	flag.StringVar(&cfgPath, "c", "config.json", "path to the configuration file (shorthand)")
@@ -33,6 +38,7 @@ func main() {
 		fmt.Fprintf(os.Stderr, "%s", meta.GetMetadataMultiline())
 		fmt.Fprintf(os.Stderr, "Usage:\n")
 		flag.PrintDefaults()
+		fmt.Fprintf(os.Stderr, "\nSubcommands:\n  replay\tAnalyze historical log files, see replay -h\n")
 	}
 
 	flag.Parse()
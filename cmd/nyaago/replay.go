@@ -0,0 +1,73 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/HT4w5/nyaago/internal/config"
+	"github.com/HT4w5/nyaago/internal/logging"
+	"github.com/HT4w5/nyaago/internal/replay"
+	"github.com/HT4w5/nyaago/pkg/meta"
+)
+
+// Replay log files through the analyzers and write the resulting rule list
+func runReplay(args []string) int {
+	fs := flag.NewFlagSet("replay", flag.ExitOnError)
+	var cfgPath string
+	var opts replay.Options
+	fs.StringVar(&cfgPath, "config", "config.json", "path to the configuration file")
+	fs.StringVar(&cfgPath, "c", "config.json", "path to the configuration file (shorthand)")
+	fs.StringVar(&opts.Source, "source", "", "ingress source whose format is used (default first source)")
+	fs.DurationVar(&opts.Interval, "interval", time.Minute, "log time between rule reports")
+	fs.StringVar(&opts.Output, "o", "", "write rules to file instead of stdout")
+
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "%s", meta.GetMetadataMultiline())
+		fmt.Fprintf(os.Stderr, "Usage: nyaago replay [flags] file... (plain, .gz or .zst, oldest first)\n")
+		fs.PrintDefaults()
+	}
+
+	fs.Parse(args)
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return exitReplayError
+	}
+
+	cfg, err := config.Load(cfgPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to load config %s: %v\n", cfgPath, err)
+		return exitConfigError
+	}
+
+	// Keep stdout for the rule list
+	if cfg.Log.Access == "" {
+		cfg.Log.Access = os.Stderr.Name()
+	}
+	err = logging.Init(&cfg.Log)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to setup logger: %v\n", err)
+		return exitLoggerError
+	}
+
+	r, err := replay.MakeReplay(cfg, opts)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to create replay: %v\n", err)
+		return exitReplayError
+	}
+	defer r.Close()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	err = r.Run(ctx, fs.Args())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Replay failed: %v\n", err)
+		return exitReplayError
+	}
+	return exitSuccess
+}
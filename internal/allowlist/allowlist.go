@@ -0,0 +1,207 @@
+package allowlist
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/netip"
+	"sync"
+	"time"
+
+	"github.com/HT4w5/nyaago/internal/config"
+	"github.com/HT4w5/nyaago/internal/dbkey"
+	"github.com/HT4w5/nyaago/internal/logging"
+	"github.com/HT4w5/nyaago/pkg/dto"
+	"github.com/dgraph-io/badger/v4"
+)
+
+const (
+	slogModuleName = "allowlist"
+	slogGroupName  = "allowlist"
+)
+
+var (
+	ErrEntryNotFound = errors.New("allowlist entry not found")
+	ErrEntryStatic   = errors.New("allowlist entry is seeded from config")
+)
+
+// Prefixes that analyzers and the rule list can never limit.
+// Entries are persisted in db and mirrored in memory for lookups
+type AllowList struct {
+	cfg     *config.AllowListConfig
+	db      *badger.DB
+	kb      dbkey.KeyBuilder
+	mu      sync.RWMutex
+	entries map[netip.Prefix]dto.AllowEntry
+	hits    map[netip.Prefix]dto.AllowHit // Keyed by suppressed rule prefix
+	logger  *slog.Logger
+}
+
+func MakeAllowList(cfg *config.AllowListConfig, db *badger.DB) (*AllowList, error) {
+	l := &AllowList{
+		cfg:     cfg,
+		db:      db,
+		kb:      dbkey.KeyBuilder{}.WithPrefix(dbkey.AllowList),
+		entries: make(map[netip.Prefix]dto.AllowEntry),
+		hits:    make(map[netip.Prefix]dto.AllowHit),
+		logger:  logging.GetLogger().With(logging.SlogKeyModule, slogModuleName).WithGroup(slogGroupName),
+	}
+
+	// Load API managed entries
+	err := l.loadEntries()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load entries: %w", err)
+	}
+
+	// Seed from config. Static entries are not persisted
+	for _, v := range cfg.Entries {
+		prefix := v.Prefix.Masked()
+		l.entries[prefix] = dto.AllowEntry{
+			Prefix:  prefix,
+			Comment: v.Comment,
+			Static:  true,
+		}
+	}
+
+	return l, nil
+}
+
+// Find an entry covering all of prefix
+func (l *AllowList) Lookup(prefix netip.Prefix) (dto.AllowEntry, bool) {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	for _, v := range l.entries {
+		if covers(v.Prefix, prefix) {
+			return v, true
+		}
+	}
+	return dto.AllowEntry{}, false
+}
+
+// Check whether a rule is suppressed by the allowlist, i.e. an entry covers its whole prefix.
+// Suppressed rules are logged and recorded as hits
+func (l *AllowList) Check(rule dto.Rule) bool {
+	entry, ok := l.Lookup(rule.Prefix)
+	if !ok {
+		return false
+	}
+	l.recordHit(rule, entry, "rule suppressed by allowlist")
+	return true
+}
+
+// Drop rules suppressed by the allowlist. Rules partially overlapping entries
+// are split into the largest prefixes that leave the entries out
+func (l *AllowList) Filter(rules []dto.Rule) []dto.Rule {
+	res := make([]dto.Rule, 0, len(rules))
+	for _, v := range rules {
+		if l.Check(v) {
+			continue
+		}
+		entries := l.overlapping(v.Prefix)
+		if len(entries) == 0 {
+			res = append(res, v)
+			continue
+		}
+		l.recordHit(v, entries[0], "rule split around allowlist")
+
+		prefixes := make([]netip.Prefix, 0, len(entries))
+		for _, e := range entries {
+			prefixes = append(prefixes, e.Prefix)
+		}
+		for _, p := range carve(v.Prefix.Masked(), prefixes) {
+			r := v
+			r.Prefix = p
+			res = append(res, r)
+		}
+	}
+	return res
+}
+
+// Get entries overlapping prefix
+func (l *AllowList) overlapping(prefix netip.Prefix) []dto.AllowEntry {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	var res []dto.AllowEntry
+	for _, v := range l.entries {
+		if v.Prefix.Overlaps(prefix) {
+			res = append(res, v)
+		}
+	}
+	return res
+}
+
+func (l *AllowList) recordHit(rule dto.Rule, entry dto.AllowEntry, msg string) {
+	l.mu.Lock()
+	_, seen := l.hits[rule.Prefix]
+	l.hits[rule.Prefix] = dto.AllowHit{
+		Rule:  rule,
+		Entry: entry,
+		Time:  time.Now(),
+	}
+	l.mu.Unlock()
+
+	// Only log the first hit of a rule to keep egress cycles quiet
+	if !seen {
+		l.logger.Info(msg, "prefix", rule.Prefix, "allow_prefix", entry.Prefix, "comment", entry.Comment, "blame", rule.Blame)
+	}
+}
+
+// Check whether a contains all of b
+func covers(a netip.Prefix, b netip.Prefix) bool {
+	return a.Bits() <= b.Bits() && a.Contains(b.Addr())
+}
+
+// Split prefix into the largest prefixes that overlap none of excluded
+func carve(prefix netip.Prefix, excluded []netip.Prefix) []netip.Prefix {
+	overlaps := false
+	for _, v := range excluded {
+		if covers(v, prefix) {
+			return nil
+		}
+		if v.Overlaps(prefix) {
+			overlaps = true
+		}
+	}
+	if !overlaps {
+		return []netip.Prefix{prefix}
+	}
+
+	lo, hi := splitPrefix(prefix)
+	return append(carve(lo, excluded), carve(hi, excluded)...)
+}
+
+// Split a masked prefix into its two halves. Overlapping a non-covering
+// prefix implies prefix is not a single address
+func splitPrefix(prefix netip.Prefix) (netip.Prefix, netip.Prefix) {
+	bits := prefix.Bits()
+	b := prefix.Addr().AsSlice()
+	b[bits/8] |= 0x80 >> (bits % 8)
+	hiAddr, _ := netip.AddrFromSlice(b)
+	return netip.PrefixFrom(prefix.Addr(), bits+1), netip.PrefixFrom(hiAddr, bits+1)
+}
+
+// List rules suppressed or split by the allowlist that have not expired yet
+func (l *AllowList) ListHits() []dto.AllowHit {
+	now := time.Now()
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	hits := make([]dto.AllowHit, 0, len(l.hits))
+	for k, v := range l.hits {
+		if v.Rule.ExpiresAt.Before(now) {
+			delete(l.hits, k)
+			continue
+		}
+		hits = append(hits, v)
+	}
+	return hits
+}
+
+func (l *AllowList) ListEntries() []dto.AllowEntry {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	entries := make([]dto.AllowEntry, 0, len(l.entries))
+	for _, v := range l.entries {
+		entries = append(entries, v)
+	}
+	return entries
+}
@@ -0,0 +1,163 @@
+package allowlist
+
+import (
+	"net/netip"
+	"slices"
+	"testing"
+
+	"github.com/HT4w5/nyaago/internal/config"
+	"github.com/HT4w5/nyaago/internal/logging"
+	"github.com/HT4w5/nyaago/pkg/dto"
+	"github.com/dgraph-io/badger/v4"
+)
+
+func makeTestAllowList(t *testing.T, entries ...string) *AllowList {
+	t.Helper()
+	err := logging.Init(&config.LogConfig{Access: "none"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	db, err := badger.Open(badger.DefaultOptions("").WithInMemory(true).WithLogger(nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	cfg := &config.AllowListConfig{}
+	for _, v := range entries {
+		cfg.Entries = append(cfg.Entries, config.AllowEntryConfig{
+			Prefix: config.IPPrefix{Prefix: netip.MustParsePrefix(v)},
+		})
+	}
+	l, err := MakeAllowList(cfg, db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return l
+}
+
+func TestCheck(t *testing.T) {
+	tests := []struct {
+		name  string
+		entry string
+		rule  string
+		want  bool
+	}{
+		{
+			name:  "same prefix",
+			entry: "192.0.2.0/24",
+			rule:  "192.0.2.0/24",
+			want:  true,
+		},
+		{
+			name:  "entry covers rule",
+			entry: "192.0.0.0/16",
+			rule:  "192.0.2.0/24",
+			want:  true,
+		},
+		{
+			name:  "entry inside rule",
+			entry: "192.0.2.7/32",
+			rule:  "192.0.2.0/24",
+			want:  false,
+		},
+		{
+			name:  "disjoint",
+			entry: "198.51.100.0/24",
+			rule:  "192.0.2.0/24",
+			want:  false,
+		},
+		{
+			name:  "other family",
+			entry: "::/0",
+			rule:  "192.0.2.0/24",
+			want:  false,
+		},
+		{
+			name:  "ipv6",
+			entry: "2001:db8::/32",
+			rule:  "2001:db8:1::/48",
+			want:  true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := makeTestAllowList(t, tt.entry)
+			got := l.Check(dto.Rule{Prefix: netip.MustParsePrefix(tt.rule)})
+			if got != tt.want {
+				t.Errorf("Check() = %v, want %v", got, tt.want)
+			}
+			if _, hit := l.hits[netip.MustParsePrefix(tt.rule)]; hit != tt.want {
+				t.Errorf("hit recorded = %v, want %v", hit, tt.want)
+			}
+		})
+	}
+}
+
+func TestFilter(t *testing.T) {
+	tests := []struct {
+		name    string
+		entries []string
+		rules   []string
+		want    []string
+	}{
+		{
+			name:    "untouched",
+			entries: []string{"198.51.100.0/24"},
+			rules:   []string{"192.0.2.0/24", "2001:db8::/64"},
+			want:    []string{"192.0.2.0/24", "2001:db8::/64"},
+		},
+		{
+			name:    "suppressed",
+			entries: []string{"192.0.2.0/23"},
+			rules:   []string{"192.0.2.0/24", "192.0.3.7/32"},
+			want:    []string{},
+		},
+		{
+			name:    "split around address",
+			entries: []string{"192.0.2.7/32"},
+			rules:   []string{"192.0.2.0/24"},
+			want: []string{
+				"192.0.2.0/30", "192.0.2.4/31", "192.0.2.6/32", "192.0.2.8/29",
+				"192.0.2.16/28", "192.0.2.32/27", "192.0.2.64/26", "192.0.2.128/25",
+			},
+		},
+		{
+			name:    "split around several entries",
+			entries: []string{"192.0.2.0/26", "192.0.2.192/26"},
+			rules:   []string{"192.0.2.0/24"},
+			want:    []string{"192.0.2.64/26", "192.0.2.128/26"},
+		},
+		{
+			name:    "split ipv6",
+			entries: []string{"2001:db8:0:1::/64"},
+			rules:   []string{"2001:db8::/63"},
+			want:    []string{"2001:db8::/64"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := makeTestAllowList(t, tt.entries...)
+			rules := make([]dto.Rule, 0, len(tt.rules))
+			for _, v := range tt.rules {
+				rules = append(rules, dto.Rule{Prefix: netip.MustParsePrefix(v), Banned: true})
+			}
+
+			got := make([]string, 0)
+			for _, v := range l.Filter(rules) {
+				if !v.Banned {
+					t.Errorf("split rule %s lost its fields", v.Prefix)
+				}
+				got = append(got, v.Prefix.String())
+			}
+			slices.Sort(got)
+			want := slices.Clone(tt.want)
+			slices.Sort(want)
+			if !slices.Equal(got, want) {
+				t.Errorf("Filter() = %v, want %v", got, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,86 @@
+package allowlist
+
+import (
+	"net/netip"
+
+	"github.com/HT4w5/nyaago/pkg/dto"
+	"github.com/dgraph-io/badger/v4"
+)
+
+func (l *AllowList) loadEntries() error {
+	return l.db.View(func(txn *badger.Txn) error {
+		opt := badger.DefaultIteratorOptions
+		opt.Prefix = l.kb.Build()
+		it := txn.NewIterator(opt)
+		defer it.Close()
+		for it.Rewind(); it.Valid(); it.Next() {
+			item := it.Item()
+			var entry dto.AllowEntry
+			err := item.Value(func(val []byte) error {
+				return entry.Unmarshal(val)
+			})
+			if err != nil {
+				return err
+			}
+			l.entries[entry.Prefix] = entry
+		}
+		return nil
+	})
+}
+
+func (l *AllowList) PutEntry(entry dto.AllowEntry) error {
+	entry.Prefix = entry.Prefix.Masked()
+	entry.Static = false
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if old, ok := l.entries[entry.Prefix]; ok && old.Static {
+		return ErrEntryStatic
+	}
+
+	entryBytes, err := entry.Marshal()
+	if err != nil {
+		return err
+	}
+	err = l.db.Update(func(txn *badger.Txn) error {
+		return txn.Set(l.kb.WithObject(entry).Build(), entryBytes)
+	})
+	if err != nil {
+		return err
+	}
+	l.entries[entry.Prefix] = entry
+	return nil
+}
+
+func (l *AllowList) GetEntry(prefix netip.Prefix) (dto.AllowEntry, error) {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	entry, ok := l.entries[prefix.Masked()]
+	if !ok {
+		return dto.AllowEntry{}, ErrEntryNotFound
+	}
+	return entry, nil
+}
+
+func (l *AllowList) DelEntry(prefix netip.Prefix) error {
+	prefix = prefix.Masked()
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	entry, ok := l.entries[prefix]
+	if !ok {
+		return ErrEntryNotFound
+	}
+	if entry.Static {
+		return ErrEntryStatic
+	}
+
+	err := l.db.Update(func(txn *badger.Txn) error {
+		return txn.Delete(l.kb.WithObject(entry).Build())
+	})
+	if err != nil {
+		return err
+	}
+	delete(l.entries, prefix)
+	return nil
+}
@@ -2,6 +2,7 @@ package analyzer
 
 import (
 	"context"
+	"time"
 
 	"github.com/HT4w5/nyaago/internal/rulelist"
 	"github.com/HT4w5/nyaago/pkg/dto"
@@ -13,3 +14,10 @@ type Analyzer interface {
 	Process(request dto.Request) error // Called when processing request
 	Report(tx *rulelist.Tx) error      // Called when generating a ruleset
 }
+
+// Analyzers doing periodic work. Start runs Tick on a wall clock ticker,
+// replay calls it directly as log time passes
+type Periodic interface {
+	Interval() time.Duration
+	Tick()
+}
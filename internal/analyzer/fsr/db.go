@@ -5,6 +5,7 @@ import (
 	"net/netip"
 	"time"
 
+	"github.com/HT4w5/nyaago/internal/clock"
 	"github.com/dgraph-io/badger/v4"
 )
 
@@ -90,6 +91,9 @@ func (fsr *FileSendRatio) getMaxHistoricRecord() (historicRecord, error) {
 			if err != nil {
 				return err
 			}
+			if clock.Expired(fsr.clk, rec.Time, time.Duration(fsr.cfg.RecordTTL)) {
+				continue
+			}
 			if rec.Ratio > maxRec.Ratio {
 				maxRec = rec
 			}
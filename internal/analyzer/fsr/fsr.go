@@ -7,6 +7,7 @@ import (
 	"net/netip"
 	"time"
 
+	"github.com/HT4w5/nyaago/internal/clock"
 	"github.com/HT4w5/nyaago/internal/config"
 	"github.com/HT4w5/nyaago/internal/dbkey"
 	"github.com/HT4w5/nyaago/internal/logging"
@@ -32,9 +33,10 @@ type FileSendRatio struct {
 	fsrKb         dbkey.KeyBuilder // for file size records
 	logger        *slog.Logger
 	blameTemplate string
+	clk           clock.Clock
 }
 
-func MakeFileSendRatio(cfg *config.FileSendRatioConfig, db *badger.DB) *FileSendRatio {
+func MakeFileSendRatio(cfg *config.FileSendRatioConfig, db *badger.DB, clk clock.Clock) *FileSendRatio {
 	kb := dbkey.KeyBuilder{}.WithPrefix(dbkey.FileSendRatio)
 	return &FileSendRatio{
 		cfg:    cfg,
@@ -45,6 +47,7 @@ func MakeFileSendRatio(cfg *config.FileSendRatioConfig, db *badger.DB) *FileSend
 		hrKb:   kb.WithPrefix(historicRecords),
 		fsrKb:  kb.WithPrefix(fileSizeRecords),
 		logger: logging.GetLogger().With(logging.SlogKeyModule, slogModuleName).WithGroup(slogGroupName),
+		clk:    clk,
 		blameTemplate: fmt.Sprintf(
 			"Fetch ratio exceeded %f.",
 			cfg.Export.RatioThreshold,
@@ -114,6 +117,9 @@ func (fsr *FileSendRatio) Report(tx *rulelist.Tx) error {
 			if err != nil {
 				return err
 			}
+			if clock.Expired(fsr.clk, rec.Time, time.Duration(fsr.cfg.RecordTTL)) {
+				continue
+			}
 			oldRec, ok := recMap[rec.Addr]
 			if !ok {
 				if rec.Ratio >= fsr.cfg.Export.RatioThreshold {
@@ -132,7 +138,7 @@ func (fsr *FileSendRatio) Report(tx *rulelist.Tx) error {
 	}
 
 	// Generate rules
-	expTime := time.Now().Add(time.Duration(fsr.cfg.Export.TTL))
+	expTime := fsr.clk.Now().Add(time.Duration(fsr.cfg.Export.TTL))
 	for _, v := range recMap {
 		// Get prefix
 		prefixLength := 32
@@ -155,7 +161,9 @@ func (fsr *FileSendRatio) Report(tx *rulelist.Tx) error {
 				v.Time.Format(time.RFC3339),
 				units.HumanDuration(v.Duration),
 			),
+			Source:    analyzerName,
 			ExpiresAt: expTime,
+			Shadow:    fsr.cfg.Export.Shadow,
 		})
 	}
 
@@ -169,7 +177,7 @@ Loop:
 	for {
 		select {
 		case <-ticker.C:
-			fsr.compileHistoricRecords()
+			fsr.Tick()
 		case <-ctx.Done():
 			break Loop
 		}
@@ -177,8 +185,12 @@ Loop:
 	fsr.logger.Info("stopping compile ticker")
 }
 
+func (fsr *FileSendRatio) Interval() time.Duration {
+	return time.Duration(fsr.cfg.UnitTime)
+}
+
 // Summarize current records and create new historic records
-func (fsr *FileSendRatio) compileHistoricRecords() {
+func (fsr *FileSendRatio) Tick() {
 	ipRecs, err := fsr.getAllIPRecords()
 	hisRecs := make([]historicRecord, 0, len(ipRecs))
 	if err != nil {
@@ -229,7 +241,7 @@ func (fsr *FileSendRatio) compileHistoricRecords() {
 			Addr:     ip.Addr,
 			Path:     maxRec.Path,
 			Ratio:    maxRatio,
-			Time:     time.Now(),
+			Time:     fsr.clk.Now(),
 			Duration: time.Duration(fsr.cfg.UnitTime),
 		}
 
@@ -5,6 +5,7 @@ import (
 	"net/netip"
 	"time"
 
+	"github.com/HT4w5/nyaago/internal/clock"
 	"github.com/dgraph-io/badger/v4"
 )
 
@@ -43,5 +44,9 @@ func (lb *LeakyBucket) getRecord(addr netip.Addr) (record, error) {
 		}
 		return record{}, err
 	}
+	// Requests without a time never stamp the record. Badger's TTL still applies to those
+	if !rec.LastModified.IsZero() && clock.Expired(lb.clk, rec.LastModified, time.Duration(lb.cfg.BucketTTL)) {
+		return record{}, ErrRecordNotFound
+	}
 	return rec, nil
 }
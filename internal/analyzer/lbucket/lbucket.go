@@ -4,9 +4,13 @@ import (
 	"context"
 	"fmt"
 	"net/netip"
+	"sync"
+	"time"
 
+	"github.com/HT4w5/nyaago/internal/clock"
 	"github.com/HT4w5/nyaago/internal/config"
 	"github.com/HT4w5/nyaago/internal/dbkey"
+	"github.com/HT4w5/nyaago/internal/pipeline"
 	"github.com/HT4w5/nyaago/internal/rulelist"
 	"github.com/HT4w5/nyaago/pkg/dto"
 	"github.com/dgraph-io/badger/v4"
@@ -21,23 +25,35 @@ type LeakyBucket struct {
 	cfg           *config.LeakyBucketConfig
 	db            *badger.DB
 	kb            dbkey.KeyBuilder
-	cachedRules   map[netip.Addr]dto.Rule
+	shards        []ruleShard // Indexed by pipeline shard of the client
 	blameTemplate string
+	clk           clock.Clock
 }
 
-func MakeLeakyBucket(cfg *config.LeakyBucketConfig, db *badger.DB) *LeakyBucket {
+// Rules of the clients on one shard. Written by the shard's worker, drained by Report
+type ruleShard struct {
+	mu          sync.Mutex
+	cachedRules map[netip.Addr]dto.Rule
+}
+
+func MakeLeakyBucket(cfg *config.LeakyBucketConfig, db *badger.DB, clk clock.Clock, shards int) *LeakyBucket {
 	kb := dbkey.KeyBuilder{}.WithPrefix(dbkey.LeakyBucket)
-	return &LeakyBucket{
-		cfg:         cfg,
-		db:          db,
-		kb:          kb,
-		cachedRules: make(map[netip.Addr]dto.Rule),
+	lb := &LeakyBucket{
+		cfg:    cfg,
+		db:     db,
+		kb:     kb,
+		shards: make([]ruleShard, shards),
+		clk:    clk,
 		blameTemplate: fmt.Sprintf(
 			"Bucket overflow. Leak rate %s. Capacity %s.",
 			units.HumanSize(float64(cfg.LeakRate)),
 			units.HumanSize(float64(cfg.Capacity)),
 		),
 	}
+	for i := range lb.shards {
+		lb.shards[i].cachedRules = make(map[netip.Addr]dto.Rule)
+	}
+	return lb
 }
 
 func (lb *LeakyBucket) Name() string {
@@ -66,9 +82,10 @@ func (lb *LeakyBucket) Process(request dto.Request) error {
 
 	// Skip bucket and time update if older than last processed request
 	if request.Time.Compare(rec.LastModified) > 0 {
-		rec.LastModified = request.Time
 		rec.Bucket = max(0, rec.Bucket-int64(request.Time.Sub(rec.LastModified).Seconds())*int64(lb.cfg.LeakRate))
+		rec.LastModified = request.Time
 	}
+	rec.Bucket += request.Sent
 
 	// Add record to cache if condition satisfies
 	if rec.Bucket > int64(lb.cfg.Capacity) {
@@ -84,16 +101,22 @@ func (lb *LeakyBucket) Process(request dto.Request) error {
 		}
 		prefix := netip.PrefixFrom(rec.Addr, prefixLength).Masked()
 
-		lb.cachedRules[rec.Addr] = dto.Rule{
+		rule := dto.Rule{
 			Prefix:    prefix,
 			Banned:    false,
 			RateLimit: int64(ratelimit),
+			Source:    analyzerName,
+			Shadow:    lb.cfg.Export.Shadow,
 			Blame: fmt.Sprintf(
 				"%s Actual volume %s.",
 				lb.blameTemplate,
 				units.BytesSize(float64(rec.Bucket)),
 			),
 		}
+		shard := &lb.shards[pipeline.Shard(rec.Addr, len(lb.shards))]
+		shard.mu.Lock()
+		shard.cachedRules[rec.Addr] = rule
+		shard.mu.Unlock()
 	}
 
 	err = lb.putRecord(rec)
@@ -105,12 +128,27 @@ func (lb *LeakyBucket) Process(request dto.Request) error {
 }
 
 func (lb *LeakyBucket) Report(tx *rulelist.Tx) error {
-	for _, v := range lb.cachedRules {
+	expTime := lb.clk.Now().Add(time.Duration(lb.cfg.Export.TTL))
+	for i := range lb.shards {
+		err := lb.reportShard(tx, &lb.shards[i], expTime)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Move the rules of a shard to tx. Rules are kept for the next report on error
+func (lb *LeakyBucket) reportShard(tx *rulelist.Tx, shard *ruleShard, expTime time.Time) error {
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+	for _, v := range shard.cachedRules {
+		v.ExpiresAt = expTime
 		err := tx.PutRule(v)
 		if err != nil {
 			return err
 		}
 	}
-	clear(lb.cachedRules)
+	clear(shard.cachedRules)
 	return nil
 }
@@ -0,0 +1,103 @@
+package lbucket
+
+import (
+	"net/netip"
+	"testing"
+	"time"
+
+	"github.com/HT4w5/nyaago/internal/clock"
+	"github.com/HT4w5/nyaago/internal/config"
+	"github.com/HT4w5/nyaago/pkg/dto"
+	"github.com/dgraph-io/badger/v4"
+)
+
+func TestLeakyBucketProcess(t *testing.T) {
+	start := time.Date(2025, time.December, 16, 0, 0, 0, 0, time.UTC)
+	client := netip.MustParseAddr("192.0.2.1")
+
+	type step struct {
+		offset     time.Duration // Request time after start
+		sent       int64
+		wantBucket int64
+		wantRule   bool // Whether a rule is pending for the client afterwards
+	}
+	tests := []struct {
+		name  string
+		steps []step
+	}{
+		{
+			name: "fills with sent bytes",
+			steps: []step{
+				{0, 6000, 6000, false},
+				{0, 4000, 10000, false},
+				{0, 1, 10001, true},
+			},
+		},
+		{
+			name: "leaks before filling",
+			steps: []step{
+				{0, 6000, 6000, false},
+				{2 * time.Second, 6000, 10000, false},
+				{4 * time.Second, 3000, 11000, true},
+			},
+		},
+		{
+			name: "empties over time",
+			steps: []step{
+				{0, 9000, 9000, false},
+				{time.Minute, 5000, 5000, false},
+			},
+		},
+		{
+			name: "late request fills without leaking",
+			steps: []step{
+				{10 * time.Second, 6000, 6000, false},
+				{0, 6000, 12000, true},
+			},
+		},
+		{
+			name: "requests without bytes are ignored",
+			steps: []step{
+				{0, 6000, 6000, false},
+				{2 * time.Second, 0, 6000, false},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, err := badger.Open(badger.DefaultOptions("").WithInMemory(true).WithLogger(nil))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer db.Close()
+			clk := &clock.Manual{}
+			clk.Set(start)
+			cfg := &config.LeakyBucketConfig{
+				LeakRate:  1000,
+				Capacity:  10000,
+				BucketTTL: config.Duration(time.Hour),
+			}
+			cfg.Export.PrefixLength.IPv4 = 24
+			lb := MakeLeakyBucket(cfg, db, clk, 1)
+
+			for k, st := range tt.steps {
+				err := lb.Process(dto.Request{Time: start.Add(st.offset), Client: client, Sent: st.sent})
+				if err != nil {
+					t.Fatalf("step %d: Process() error = %v", k, err)
+				}
+				rec, err := lb.getRecord(client)
+				if err != nil {
+					t.Fatalf("step %d: getRecord() error = %v", k, err)
+				}
+				if rec.Bucket != st.wantBucket {
+					t.Errorf("step %d: bucket = %d, want %d", k, rec.Bucket, st.wantBucket)
+				}
+				_, ok := lb.shards[0].cachedRules[client]
+				if ok != st.wantRule {
+					t.Errorf("step %d: rule pending = %v, want %v", k, ok, st.wantRule)
+				}
+			}
+		})
+	}
+}
@@ -7,6 +7,7 @@ import (
 	"github.com/HT4w5/nyaago/internal/analyzer/fsr"
 	"github.com/HT4w5/nyaago/internal/analyzer/lbucket"
 	"github.com/HT4w5/nyaago/internal/analyzer/rfreq"
+	"github.com/HT4w5/nyaago/internal/clock"
 	"github.com/HT4w5/nyaago/internal/config"
 	"github.com/HT4w5/nyaago/internal/logging"
 	"github.com/HT4w5/nyaago/internal/rulelist"
@@ -26,7 +27,8 @@ type AnalyzerManager struct {
 	logger    *slog.Logger
 }
 
-func MakeAnalyzerManager(cfg *config.AnaylzerConfig, db *badger.DB) *AnalyzerManager {
+// shards is the number of analysis workers calling Process concurrently, each with its own clients
+func MakeAnalyzerManager(cfg *config.AnaylzerConfig, db *badger.DB, clk clock.Clock, shards int) *AnalyzerManager {
 	am := AnalyzerManager{
 		cfg:       cfg,
 		db:        db,
@@ -36,13 +38,13 @@ func MakeAnalyzerManager(cfg *config.AnaylzerConfig, db *badger.DB) *AnalyzerMan
 
 	// Make analyzers
 	if cfg.LeakyBucket.Enabled {
-		am.analyzers = append(am.analyzers, lbucket.MakeLeakyBucket(&cfg.LeakyBucket, db))
+		am.analyzers = append(am.analyzers, lbucket.MakeLeakyBucket(&cfg.LeakyBucket, db, clk, shards))
 	}
 	if cfg.FileSendRatio.Enabled {
-		am.analyzers = append(am.analyzers, fsr.MakeFileSendRatio(&cfg.FileSendRatio, db))
+		am.analyzers = append(am.analyzers, fsr.MakeFileSendRatio(&cfg.FileSendRatio, db, clk))
 	}
 	if cfg.RequestFrequency.Enabled {
-		am.analyzers = append(am.analyzers, rfreq.MakeRequestFrequency(&cfg.RequestFrequency, db))
+		am.analyzers = append(am.analyzers, rfreq.MakeRequestFrequency(&cfg.RequestFrequency, db, clk, shards))
 	}
 
 	return &am
@@ -66,6 +68,27 @@ func (am *AnalyzerManager) Start(ctx context.Context) error {
 	return nil
 }
 
+// Get an enabled analyzer by name. A nil manager has no analyzers
+func (am *AnalyzerManager) Lookup(name string) (Analyzer, bool) {
+	if am == nil {
+		return nil, false
+	}
+	for _, v := range am.analyzers {
+		if v.Name() == name {
+			return v, true
+		}
+	}
+	return nil, false
+}
+
+// Get all enabled analyzers. A nil manager has no analyzers
+func (am *AnalyzerManager) Analyzers() []Analyzer {
+	if am == nil {
+		return nil
+	}
+	return am.analyzers
+}
+
 // Send a request to enabled analyzers
 func (am *AnalyzerManager) Process(request dto.Request) {
 	am.logger.Debug("processing request", "request", request)
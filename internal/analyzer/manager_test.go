@@ -0,0 +1,132 @@
+package analyzer
+
+import (
+	"net/netip"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/HT4w5/nyaago/internal/allowlist"
+	"github.com/HT4w5/nyaago/internal/clock"
+	"github.com/HT4w5/nyaago/internal/config"
+	"github.com/HT4w5/nyaago/internal/logging"
+	"github.com/HT4w5/nyaago/internal/pipeline"
+	"github.com/HT4w5/nyaago/internal/rulelist"
+	"github.com/HT4w5/nyaago/pkg/dto"
+	"github.com/dgraph-io/badger/v4"
+)
+
+// Workers process their shard's clients while reports and ticks run, as in the server.
+// Run with -race
+func TestConcurrentProcess(t *testing.T) {
+	const (
+		workers  = 8
+		clients  = 64
+		requests = 10
+	)
+
+	err := logging.Init(&config.LogConfig{Access: "none"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	db, err := badger.Open(badger.DefaultOptions("").WithInMemory(true).WithLogger(nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	cfg := &config.Config{}
+	lb := &cfg.Analyzers.LeakyBucket
+	lb.Enabled = true
+	lb.Capacity = 1000
+	lb.BucketTTL = config.Duration(time.Hour)
+	lb.Export.PrefixLength.IPv4 = 24
+	lb.Export.TTL = config.Duration(time.Hour)
+	rf := &cfg.Analyzers.RequestFrequency
+	rf.Enabled = true
+	rf.UnitTime = config.Duration(time.Minute)
+	rf.RecordTTL = config.Duration(time.Hour)
+	rf.Export.PrefixLength.IPv4 = 32
+	rf.Export.TTL = config.Duration(time.Hour)
+
+	al, err := allowlist.MakeAllowList(&cfg.AllowList, db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rl, err := rulelist.MakeRuleList(cfg, db, al, clock.Wall{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	am := MakeAnalyzerManager(&cfg.Analyzers, db, clock.Wall{}, workers)
+
+	// One client per /24, so each analyzer reports one rule per client
+	shards := make([][]netip.Addr, workers)
+	for i := range clients {
+		addr := netip.AddrFrom4([4]byte{10, 0, byte(i), 1})
+		shard := pipeline.Shard(addr, workers)
+		shards[shard] = append(shards[shard], addr)
+	}
+
+	done := make(chan struct{})
+	var reporter sync.WaitGroup
+	reporter.Add(1)
+	go func() {
+		defer reporter.Done()
+		for {
+			select {
+			case <-done:
+				return
+			default:
+			}
+			am.SaveRules(rl)
+			for _, v := range am.Analyzers() {
+				if p, ok := v.(Periodic); ok {
+					p.Tick()
+				}
+			}
+		}
+	}()
+
+	var wg sync.WaitGroup
+	for _, addrs := range shards {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for n := range requests {
+				for _, addr := range addrs {
+					am.Process(dto.Request{
+						Time:   time.Now(),
+						Client: addr,
+						URL:    "/file",
+						Sent:   int64(100 * (n + 1)),
+					})
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	close(done)
+	reporter.Wait()
+
+	// Flush what arrived after the last report
+	for _, v := range am.Analyzers() {
+		if p, ok := v.(Periodic); ok {
+			p.Tick()
+		}
+	}
+	am.SaveRules(rl)
+
+	rules, err := rl.ListRules()
+	if err != nil {
+		t.Fatal(err)
+	}
+	counts := make(map[string]int)
+	for _, v := range rules {
+		counts[v.Source]++
+	}
+	for _, v := range am.Analyzers() {
+		if counts[v.Name()] != clients {
+			t.Errorf("%s reported %d rules, want %d", v.Name(), counts[v.Name()], clients)
+		}
+	}
+}
@@ -11,6 +11,7 @@ import (
 type record struct {
 	Addr     netip.Addr
 	RPS      float64
+	Time     time.Time // Compiled at, on the analyzer clock
 	Duration time.Duration
 }
 
@@ -5,11 +5,14 @@ import (
 	"fmt"
 	"log/slog"
 	"net/netip"
+	"sync"
 	"time"
 
+	"github.com/HT4w5/nyaago/internal/clock"
 	"github.com/HT4w5/nyaago/internal/config"
 	"github.com/HT4w5/nyaago/internal/dbkey"
 	"github.com/HT4w5/nyaago/internal/logging"
+	"github.com/HT4w5/nyaago/internal/pipeline"
 	"github.com/HT4w5/nyaago/internal/rulelist"
 	"github.com/HT4w5/nyaago/pkg/dto"
 	"github.com/dgraph-io/badger/v4"
@@ -26,23 +29,35 @@ type RequestFrequency struct {
 	db            *badger.DB
 	kb            dbkey.KeyBuilder
 	logger        *slog.Logger
-	reqCountMap   map[netip.Addr]int
+	shards        []countShard // Indexed by pipeline shard of the client
 	blameTemplate string
+	clk           clock.Clock
 }
 
-func MakeRequestFrequency(cfg *config.RequestFrequencyConfig, db *badger.DB) *RequestFrequency {
+// Request counts of the clients on one shard. Written by the shard's worker, drained by Tick
+type countShard struct {
+	mu          sync.Mutex
+	reqCountMap map[netip.Addr]int
+}
+
+func MakeRequestFrequency(cfg *config.RequestFrequencyConfig, db *badger.DB, clk clock.Clock, shards int) *RequestFrequency {
 	kb := dbkey.KeyBuilder{}.WithPrefix(dbkey.RequestFrequency)
-	return &RequestFrequency{
-		cfg:         cfg,
-		db:          db,
-		kb:          kb,
-		logger:      logging.GetLogger().With(logging.SlogKeyModule, slogModuleName).WithGroup(slogGroupName),
-		reqCountMap: make(map[netip.Addr]int),
+	rf := &RequestFrequency{
+		cfg:    cfg,
+		db:     db,
+		kb:     kb,
+		logger: logging.GetLogger().With(logging.SlogKeyModule, slogModuleName).WithGroup(slogGroupName),
+		shards: make([]countShard, shards),
+		clk:    clk,
 		blameTemplate: fmt.Sprintf(
 			"RPS exceeded %f.",
 			cfg.RPSThreshold,
 		),
 	}
+	for i := range rf.shards {
+		rf.shards[i].reqCountMap = make(map[netip.Addr]int)
+	}
+	return rf
 }
 
 func (rf *RequestFrequency) Name() string {
@@ -56,7 +71,10 @@ func (rf *RequestFrequency) Start(ctx context.Context) error {
 }
 
 func (rf *RequestFrequency) Process(request dto.Request) error {
-	rf.reqCountMap[request.Client]++
+	shard := &rf.shards[pipeline.Shard(request.Client, len(rf.shards))]
+	shard.mu.Lock()
+	shard.reqCountMap[request.Client]++
+	shard.mu.Unlock()
 	return nil
 }
 
@@ -76,6 +94,9 @@ func (rf *RequestFrequency) Report(tx *rulelist.Tx) error {
 			if err != nil {
 				return err
 			}
+			if clock.Expired(rf.clk, rec.Time, time.Duration(rf.cfg.RecordTTL)) {
+				continue
+			}
 			oldRec, ok := recMap[rec.Addr]
 			if !ok {
 				if rec.RPS >= rf.cfg.RPSThreshold {
@@ -94,7 +115,7 @@ func (rf *RequestFrequency) Report(tx *rulelist.Tx) error {
 	}
 
 	// Generate rules
-	expTime := time.Now().Add(time.Duration(rf.cfg.Export.TTL))
+	expTime := rf.clk.Now().Add(time.Duration(rf.cfg.Export.TTL))
 	for _, v := range recMap {
 		// Get prefix
 		prefixLength := 32
@@ -113,31 +134,44 @@ func (rf *RequestFrequency) Report(tx *rulelist.Tx) error {
 				rf.blameTemplate,
 				v.RPS,
 			),
+			Source:    analyzerName,
 			ExpiresAt: expTime,
+			Shadow:    rf.cfg.Export.Shadow,
 		})
 	}
 
 	return nil
 }
 
-func (rf *RequestFrequency) compileRecords() {
-	recs := make([]record, 0, len(rf.reqCountMap))
-	for k, v := range rf.reqCountMap {
-		rec := record{
-			Addr:     k,
-			RPS:      float64(v) / float64(rf.cfg.UnitTime),
-			Duration: time.Duration(rf.cfg.UnitTime),
+func (rf *RequestFrequency) Interval() time.Duration {
+	return time.Duration(rf.cfg.UnitTime)
+}
+
+// Compile request counts of the past unit time into records
+func (rf *RequestFrequency) Tick() {
+	now := rf.clk.Now()
+	recs := make([]record, 0)
+	for i := range rf.shards {
+		shard := &rf.shards[i]
+		shard.mu.Lock()
+		for k, v := range shard.reqCountMap {
+			rec := record{
+				Addr:     k,
+				RPS:      float64(v) / float64(rf.cfg.UnitTime),
+				Time:     now,
+				Duration: time.Duration(rf.cfg.UnitTime),
+			}
+			recs = append(recs, rec)
 		}
-		recs = append(recs, rec)
+		// Clean-up
+		clear(shard.reqCountMap)
+		shard.mu.Unlock()
 	}
 
 	err := rf.putRecords(recs)
 	if err != nil {
 		rf.logger.Error("failed to put records", logging.SlogKeyError, err)
 	}
-
-	// Clean-up
-	clear(rf.reqCountMap)
 }
 
 func (rf *RequestFrequency) compileTicker(ctx context.Context) {
@@ -147,7 +181,7 @@ Loop:
 	for {
 		select {
 		case <-ticker.C:
-			rf.compileRecords()
+			rf.Tick()
 		case <-ctx.Done():
 			break Loop
 		}
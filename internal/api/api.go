@@ -2,17 +2,21 @@ package api
 
 import (
 	"context"
+	"crypto/subtle"
 	"log/slog"
 	"net/http"
+	"strings"
 
 	"github.com/HT4w5/nyaago/internal/config"
 	"github.com/HT4w5/nyaago/internal/logging"
 	"github.com/HT4w5/nyaago/internal/server"
+	"github.com/HT4w5/nyaago/pkg/dto"
 	"github.com/gin-gonic/gin"
 	sloggin "github.com/samber/slog-gin"
 )
 
 type API struct {
+	cfg    *config.APIConfig
 	engine *gin.Engine
 	http   *http.Server
 	srv    *server.Server
@@ -30,6 +34,7 @@ const (
 func MakeAPI(cfg *config.Config, s *server.Server) (*API, error) {
 	logger := logging.GetLogger()
 	api := &API{
+		cfg:    &cfg.API,
 		engine: gin.New(),
 		logger: logger.With(logging.SlogKeyModule, slogModuleName).WithGroup(slogGroupName),
 		srv:    s,
@@ -44,6 +49,9 @@ func MakeAPI(cfg *config.Config, s *server.Server) (*API, error) {
 	}))
 	api.engine.Use(gin.Recovery())
 	api.setupRoutesV1()
+	if cfg.API.Token == "" {
+		api.logger.Warn("api token not set, anyone reaching the api can change rules")
+	}
 
 	// Setup HTTP server
 	api.http = &http.Server{
@@ -54,6 +62,18 @@ func MakeAPI(cfg *config.Config, s *server.Server) (*API, error) {
 	return api, nil
 }
 
+// Reject requests without the configured bearer token
+func (api *API) requireToken(c *gin.Context) {
+	if api.cfg.Token == "" {
+		return
+	}
+	token, ok := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer ")
+	if !ok || subtle.ConstantTimeCompare([]byte(token), []byte(api.cfg.Token)) != 1 {
+		c.Header("WWW-Authenticate", "Bearer")
+		c.AbortWithStatusJSON(http.StatusUnauthorized, dto.ErrorJSON{Error: "unauthorized"})
+	}
+}
+
 func (api *API) Start() {
 	api.logger.Info("starting")
 	// HTTP server
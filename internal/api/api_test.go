@@ -0,0 +1,80 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/HT4w5/nyaago/internal/config"
+	"github.com/HT4w5/nyaago/internal/logging"
+	"github.com/gin-gonic/gin"
+)
+
+func TestRequireToken(t *testing.T) {
+	tests := []struct {
+		name     string
+		token    string // Configured token
+		header   string
+		wantCode int
+	}{
+		{"no token configured", "", "", http.StatusOK},
+		{"valid token", "secret", "Bearer secret", http.StatusOK},
+		{"missing token", "secret", "", http.StatusUnauthorized},
+		{"wrong token", "secret", "Bearer wrong", http.StatusUnauthorized},
+		{"wrong scheme", "secret", "Basic secret", http.StatusUnauthorized},
+	}
+
+	gin.SetMode(gin.TestMode)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			api := &API{cfg: &config.APIConfig{Token: tt.token}}
+			engine := gin.New()
+			engine.PUT("/", api.requireToken, func(c *gin.Context) { c.Status(http.StatusOK) })
+
+			req := httptest.NewRequest(http.MethodPut, "/", nil)
+			if tt.header != "" {
+				req.Header.Set("Authorization", tt.header)
+			}
+			res := httptest.NewRecorder()
+			engine.ServeHTTP(res, req)
+			if res.Code != tt.wantCode {
+				t.Errorf("status = %d, want %d", res.Code, tt.wantCode)
+			}
+		})
+	}
+}
+
+// Every route changing rules requires the token. Handlers are never reached
+func TestMutatingRoutesRequireToken(t *testing.T) {
+	err := logging.Init(&config.LogConfig{Access: "none"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	gin.SetMode(gin.TestMode)
+	cfg := &config.Config{}
+	cfg.API.Token = "secret"
+	api, err := MakeAPI(cfg, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	checked := 0
+	for _, route := range api.engine.Routes() {
+		if route.Method == http.MethodGet {
+			continue
+		}
+		checked++
+		path := strings.NewReplacer(":addr", "192.0.2.0", ":bits", "24").Replace(route.Path)
+		req := httptest.NewRequest(route.Method, path, strings.NewReader("{}"))
+		res := httptest.NewRecorder()
+		api.engine.ServeHTTP(res, req)
+		if res.Code != http.StatusUnauthorized {
+			t.Errorf("%s %s without token = %d, want %d", route.Method, route.Path, res.Code, http.StatusUnauthorized)
+		}
+	}
+	// Rules and allowlist PUT and DELETE routes
+	if checked != 8 {
+		t.Errorf("checked %d mutating routes, want 8", checked)
+	}
+}
@@ -4,13 +4,32 @@ package api
 
 func (api *API) setupRoutesV1() {
 	api.engine.GET("/v1/ping", api.srv.HandlePing)
+	// Routes changing rules or the allowlist. Rule changes can also run post_exec commands
+	auth := api.engine.Group("", api.requireToken)
 
 	// Rules endpoint
 	api.engine.GET("/v1/rules", api.srv.HandleGetRules)
-	// api.engine.GET("/v1/rules/:addr", api.srv.HandleGetRule)
-	// api.engine.PUT("/v1/rules/:addr", api.srv.HandlePutRule)
-	// api.engine.DELETE("/v1/rules/:addr", api.srv.HandleDeleteRule)
+	auth.PUT("/v1/rules", api.srv.HandlePutRule)
+	api.engine.GET("/v1/rules/shadow", api.srv.HandleGetShadowRules)
+	api.engine.GET("/v1/rules/:addr", api.srv.HandleGetRule)
+	auth.PUT("/v1/rules/:addr", api.srv.HandlePutRule)
+	auth.DELETE("/v1/rules/:addr", api.srv.HandleDeleteRule)
+	// Prefix form, e.g. /v1/rules/192.0.2.0/24
+	api.engine.GET("/v1/rules/:addr/:bits", api.srv.HandleGetRule)
+	auth.PUT("/v1/rules/:addr/:bits", api.srv.HandlePutRule)
+	auth.DELETE("/v1/rules/:addr/:bits", api.srv.HandleDeleteRule)
 
-	// Record endpoint
-	api.engine.GET("/v1/records", api.srv.HandleGetRecords)
+	// Egress endpoint
+	api.engine.GET("/v1/egress", api.srv.HandleGetEgress)
+
+	// Ingress endpoint
+	api.engine.GET("/v1/ingress/stats", api.srv.HandleGetIngressStats)
+	api.engine.GET("/v1/ingress/rejections", api.srv.HandleGetIngressRejections)
+
+	// Allowlist endpoint
+	api.engine.GET("/v1/allowlist", api.srv.HandleGetAllowList)
+	auth.PUT("/v1/allowlist", api.srv.HandlePutAllowEntry)
+	api.engine.GET("/v1/allowlist/hits", api.srv.HandleGetAllowListHits)
+	auth.DELETE("/v1/allowlist/:addr", api.srv.HandleDeleteAllowEntry)
+	auth.DELETE("/v1/allowlist/:addr/:bits", api.srv.HandleDeleteAllowEntry)
 }
@@ -0,0 +1,52 @@
+package clock
+
+import (
+	"sync"
+	"time"
+)
+
+// Source of the current time. Live operation uses Wall, replay drives a Manual clock from log timestamps
+type Clock interface {
+	Now() time.Time
+}
+
+type Wall struct{}
+
+func (Wall) Now() time.Time {
+	return time.Now()
+}
+
+// Clock set by the caller. It never moves backwards
+type Manual struct {
+	mu  sync.RWMutex
+	now time.Time
+}
+
+func (c *Manual) Now() time.Time {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.now
+}
+
+// Advance to t. Earlier times are ignored
+func (c *Manual) Set(t time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if t.After(c.now) {
+		c.now = t
+	}
+}
+
+// Whether a record stamped at t on clk has outlived ttl.
+// Badger TTLs run on wall time, so replay checks record age against its own clock
+func Expired(clk Clock, t time.Time, ttl time.Duration) bool {
+	return clk.Now().Sub(t) > ttl
+}
+
+// Convert a time on clk to wall time, e.g. for badger expiry
+func ToWall(clk Clock, t time.Time) time.Time {
+	if _, ok := clk.(Wall); ok {
+		return t
+	}
+	return time.Now().Add(t.Sub(clk.Now()))
+}
@@ -0,0 +1,81 @@
+package clock
+
+import (
+	"testing"
+	"time"
+)
+
+var base = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+func TestManual(t *testing.T) {
+	tests := []struct {
+		name string
+		set  []time.Time
+		want time.Time
+	}{
+		{
+			name: "unset",
+			want: time.Time{},
+		},
+		{
+			name: "forward",
+			set:  []time.Time{base, base.Add(time.Minute)},
+			want: base.Add(time.Minute),
+		},
+		{
+			name: "never backwards",
+			set:  []time.Time{base.Add(time.Minute), base},
+			want: base.Add(time.Minute),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var c Manual
+			for _, v := range tt.set {
+				c.Set(v)
+			}
+			if got := c.Now(); !got.Equal(tt.want) {
+				t.Errorf("Now() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExpired(t *testing.T) {
+	c := &Manual{}
+	c.Set(base)
+
+	tests := []struct {
+		name string
+		t    time.Time
+		want bool
+	}{
+		{"fresh", base.Add(-time.Minute), false},
+		{"at ttl", base.Add(-time.Hour), false},
+		{"past ttl", base.Add(-time.Hour - time.Second), true},
+		{"future", base.Add(time.Minute), false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Expired(c, tt.t, time.Hour); got != tt.want {
+				t.Errorf("Expired() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestToWall(t *testing.T) {
+	now := time.Now()
+	if got := ToWall(Wall{}, now); !got.Equal(now) {
+		t.Errorf("ToWall(Wall) = %s, want %s", got, now)
+	}
+
+	c := &Manual{}
+	c.Set(base)
+	got := ToWall(c, base.Add(time.Hour))
+	if d := time.Until(got); d <= 59*time.Minute || d > time.Hour {
+		t.Errorf("ToWall(Manual) is %s from now, want 1h", d)
+	}
+}
@@ -0,0 +1,10 @@
+package config
+
+type AllowListConfig struct {
+	Entries []AllowEntryConfig `json:"entries"` // Seeded on start. Cannot be removed through the API
+}
+
+type AllowEntryConfig struct {
+	Prefix  IPPrefix `json:"prefix"`
+	Comment string   `json:"comment"`
+}
@@ -4,7 +4,7 @@ package config
 type AnaylzerConfig struct {
 	LeakyBucket      LeakyBucketConfig      `json:"leaky_bucket"`
 	FileSendRatio    FileSendRatioConfig    `json:"file_send_ratio"`
-	RequestFrequency RequestFrequencyConfig `json:"request_frequecy"`
+	RequestFrequency RequestFrequencyConfig `json:"request_frequency"`
 }
 
 // Config for leaky bucket analyzer
@@ -50,5 +50,6 @@ type ExportCommonConfig struct {
 		IPv4 int `json:"ipv4"` // Affected range for IPv4
 		IPv6 int `json:"ipv6"` // Affected range for IPv6
 	} `json:"prefix_length"`
-	TTL Duration `json:"ttl"` // Exported rule's time to live
+	TTL    Duration `json:"ttl"`    // Exported rule's time to live
+	Shadow bool     `json:"shadow"` // Export rules to the shadow rule list only
 }
@@ -1,5 +1,6 @@
 package config
 
 type APIConfig struct {
-	ListenAddr string `json:"listen_addr"`
+	ListenAddr string `json:"listen_addr"` // Default 127.0.0.1:8580
+	Token      string `json:"token"`       // Bearer token required to change rules and the allowlist. Empty disables authentication
 }
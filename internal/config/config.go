@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"os"
+	"runtime"
 	"time"
 )
 
@@ -13,12 +14,16 @@ type ConfigObject interface {
 }
 
 type Config struct {
-	Log      LogConfig      `json:"log"`
-	DB       DBConfig       `json:"db"`
-	RuleList RuleListConfig `json:"ip_list"`
-	Ingress  IngressConfig  `json:"ingress"`
-	Egress   EgressConfig   `json:"egress"`
-	API      APIConfig      `json:"api"`
+	Log       LogConfig       `json:"log"`
+	DB        DBConfig        `json:"db"`
+	RuleList  RuleListConfig  `json:"ip_list"`
+	AllowList AllowListConfig `json:"allow_list"`
+	Router    RouterConfig    `json:"router"`
+	Analyzers AnaylzerConfig  `json:"analyzers"`
+	Ingress   []IngressConfig `json:"ingress"`
+	Pipeline  PipelineConfig  `json:"pipeline"`
+	Egress    []EgressConfig  `json:"egress"`
+	API       APIConfig       `json:"api"`
 }
 
 func Load(path string) (*Config, error) {
@@ -47,9 +52,31 @@ func getDefault() Config {
 	// DB
 	cfg.DB.Dir = "./nyaago.db"
 
-	// Ingress
-	cfg.Ingress.Syslog.Transport = "udp"
-	cfg.Ingress.Syslog.ListenAddr = "0.0.0.0:514"
+	// Router
+	cfg.Router.Flow.Action = "forward"
+
+	// Analyzers
+	cfg.Analyzers.LeakyBucket.BucketTTL = Duration(time.Hour)
+	cfg.Analyzers.LeakyBucket.Export.PrefixLength.IPv4 = 24
+	cfg.Analyzers.LeakyBucket.Export.PrefixLength.IPv6 = 64
+	cfg.Analyzers.LeakyBucket.Export.TTL = Duration(30 * time.Minute)
+	cfg.Analyzers.FileSendRatio.UnitTime = Duration(time.Hour)
+	cfg.Analyzers.FileSendRatio.RecordTTL = Duration(24 * time.Hour)
+	cfg.Analyzers.FileSendRatio.SizeInfoTTL = Duration(24 * time.Hour)
+	cfg.Analyzers.FileSendRatio.Export.PrefixLength.IPv4 = 24
+	cfg.Analyzers.FileSendRatio.Export.PrefixLength.IPv6 = 64
+	cfg.Analyzers.FileSendRatio.Export.TTL = Duration(24 * time.Hour)
+	cfg.Analyzers.RequestFrequency.UnitTime = Duration(time.Minute)
+	cfg.Analyzers.RequestFrequency.RecordTTL = Duration(time.Hour)
+	cfg.Analyzers.RequestFrequency.Export.PrefixLength.IPv4 = 32
+	cfg.Analyzers.RequestFrequency.Export.PrefixLength.IPv6 = 64
+	cfg.Analyzers.RequestFrequency.Export.TTL = Duration(time.Hour)
+
+	// Pipeline
+	cfg.Pipeline.Workers = runtime.NumCPU()
+	cfg.Pipeline.BufferSize = 65536
+	cfg.Pipeline.Overflow = "block"
+	cfg.Pipeline.SampleRate = 10
 
 	// RuleList
 	cfg.RuleList.EntryTTL = Duration(30 * time.Minute)
@@ -57,12 +84,73 @@ func getDefault() Config {
 	cfg.RuleList.ExportPrefixLength.IPv6 = 64
 
 	// API
-	cfg.API.ListenAddr = "0.0.0.0:8580"
+	cfg.API.ListenAddr = "127.0.0.1:8580"
 
 	return cfg
 }
 
 func (cfg Config) verify() error {
+	// Ingress
+	if len(cfg.Ingress) == 0 {
+		return fmt.Errorf("no ingress source configured")
+	}
+	names := make([]string, 0, len(cfg.Ingress))
+	for _, v := range cfg.Ingress {
+		if v.Name == "" {
+			return fmt.Errorf("ingress source without name")
+		}
+		if inValidList(v.Name, names) {
+			return fmt.Errorf("duplicate ingress source name: %s", v.Name)
+		}
+		names = append(names, v.Name)
+		if v.Method == "tail" && v.Tail.CheckpointInterval <= 0 {
+			return fmt.Errorf("ingress source %s: checkpoint_interval must be positive", v.Name)
+		}
+		if v.Method == "syslog" && v.Syslog.Transport == "tls" && (v.Syslog.TLS.CertFile == "" || v.Syslog.TLS.KeyFile == "") {
+			return fmt.Errorf("ingress source %s: tls transport requires cert_file and key_file", v.Name)
+		}
+		if v.Method == "forward" && v.Forward.MaxChunkSize <= 0 {
+			return fmt.Errorf("ingress source %s: max_chunk_size must be positive", v.Name)
+		}
+		if v.Method == "syslog" && v.Syslog.MaxMessageSize <= 0 {
+			return fmt.Errorf("ingress source %s: max_message_size must be positive", v.Name)
+		}
+		if len(v.TrustedProxies) > 0 && inValidList(v.Format, []string{"nginxcombined", "nginxcombinedext", "haproxy", "apachecombined"}) {
+			return fmt.Errorf("ingress source %s: format %s carries no forwarding fields for trusted_proxies", v.Name, v.Format)
+		}
+	}
+
+	// Pipeline
+	if cfg.Pipeline.Workers <= 0 {
+		return fmt.Errorf("pipeline workers must be positive")
+	}
+	if cfg.Pipeline.BufferSize <= 0 {
+		return fmt.Errorf("pipeline buffer_size must be positive")
+	}
+	if !inValidList(cfg.Pipeline.Overflow, []string{"block", "drop_oldest", "drop_newest", "sample"}) {
+		return fmt.Errorf("unsupported pipeline overflow policy: %s", cfg.Pipeline.Overflow)
+	}
+	if cfg.Pipeline.Overflow == "sample" && cfg.Pipeline.SampleRate <= 0 {
+		return fmt.Errorf("pipeline sample_rate must be positive")
+	}
+
+	// Egress
+	names = make([]string, 0, len(cfg.Egress))
+	for _, v := range cfg.Egress {
+		if v.Name == "" {
+			return fmt.Errorf("egress target without name")
+		}
+		if inValidList(v.Name, names) {
+			return fmt.Errorf("duplicate egress target name: %s", v.Name)
+		}
+		names = append(names, v.Name)
+		if v.Interval <= 0 {
+			return fmt.Errorf("egress target %s: interval must be positive", v.Name)
+		}
+		if !inValidList(v.Filter.Kind, []string{"", "ban", "limit"}) {
+			return fmt.Errorf("egress target %s: unsupported filter kind: %s", v.Name, v.Filter.Kind)
+		}
+	}
 	return nil
 }
 
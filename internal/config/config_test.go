@@ -0,0 +1,66 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLoadVerify(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  string
+		wantErr bool
+	}{
+		{
+			name:   "minimal",
+			config: `{"ingress": [{"name": "web", "method": "tail", "format": "nginxcombined"}]}`,
+		},
+		{
+			name:    "no ingress",
+			config:  `{}`,
+			wantErr: true,
+		},
+		{
+			name:   "trusted_proxies with forwarding fields",
+			config: `{"ingress": [{"name": "web", "method": "tail", "format": "nginxjson", "trusted_proxies": ["10.0.0.0/8"]}]}`,
+		},
+		{
+			name:    "trusted_proxies without forwarding fields",
+			config:  `{"ingress": [{"name": "web", "method": "tail", "format": "haproxy", "trusted_proxies": ["10.0.0.0/8"]}]}`,
+			wantErr: true,
+		},
+		{
+			name: "egress filter",
+			config: `{"ingress": [{"name": "web", "method": "tail", "format": "nginxcombined"}],
+				"egress": [{"name": "fw", "interval": "1m", "format": "nftables",
+					"filter": {"kind": "ban", "sources": ["manual"], "blame": "^abuse"}}]}`,
+		},
+		{
+			name: "unsupported egress filter kind",
+			config: `{"ingress": [{"name": "web", "method": "tail", "format": "nginxcombined"}],
+				"egress": [{"name": "fw", "interval": "1m", "format": "nftables", "filter": {"kind": "deny"}}]}`,
+			wantErr: true,
+		},
+		{
+			name: "bad egress filter blame",
+			config: `{"ingress": [{"name": "web", "method": "tail", "format": "nginxcombined"}],
+				"egress": [{"name": "fw", "interval": "1m", "format": "nftables", "filter": {"blame": "("}}]}`,
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "config.json")
+			err := os.WriteFile(path, []byte(tt.config), 0644)
+			if err != nil {
+				t.Fatal(err)
+			}
+			_, err = Load(path)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Load() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -1,10 +1,24 @@
 package config
 
+// A named egress target
 type EgressConfig struct {
-	Interval Duration         `json:"interval"`
-	Path     string           `json:"path"`
-	Format   string           `json:"format"`
-	PostExec []PostExecConfig `json:"post_exec"`
+	Name        string             `json:"name"`
+	Interval    Duration           `json:"interval"`
+	MaxInterval Duration           `json:"max_interval"` // Rewrite and run post_exec after this long even if rules are unchanged. 0 to disable
+	Path        string             `json:"path"`
+	Format      string             `json:"format"`
+	Filter      EgressFilterConfig `json:"filter"`
+	Shadow      bool               `json:"shadow"`      // Dry run. Skip writing path and running post_exec
+	ShadowPath  string             `json:"shadow_path"` // Write enforced and shadow rules here. Empty to disable
+	Validate    *PostExecConfig    `json:"validate"`    // Must succeed with the new file in place, otherwise it is rolled back
+	PostExec    []PostExecConfig   `json:"post_exec"`
+}
+
+// Select rules exported by a target. Empty fields match all rules
+type EgressFilterConfig struct {
+	Kind    string   `json:"kind"`    // "ban" for banned rules only, "limit" for rate limited rules only
+	Sources []string `json:"sources"` // Rule sources, analyzer names or "manual"
+	Blame   *Regexp  `json:"blame"`
 }
 
 type PostExecConfig struct {
@@ -1,18 +1,116 @@
 package config
 
+import (
+	"encoding/json"
+	"time"
+)
+
 type IngressConfig struct {
-	Method string       `json:"method"`
-	Format string       `json:"format"`
-	Syslog SyslogConfig `json:"syslog"`
-	Tail   TailConfig   `json:"tail"`
+	Name    string            `json:"name"`
+	Tag     string            `json:"tag"` // Stamped on requests from this source. Optional
+	Method  string            `json:"method"`
+	Format  string            `json:"format"`
+	Syslog  SyslogConfig      `json:"syslog"`
+	Tail    TailConfig        `json:"tail"`
+	HTTP    HTTPIngressConfig `json:"http"`
+	Forward ForwardConfig     `json:"forward"`
+	Regex   RegexParserConfig `json:"regex"` // Used by format regex
+	JSON    JSONParserConfig  `json:"json"`  // Used by format json
+
+	// Requests from these prefixes have their client taken from forwarding fields in the log line.
+	// Not supported by nginxcombined, nginxcombinedext, haproxy and apachecombined, which log none
+	TrustedProxies []IPPrefix `json:"trusted_proxies"`
+}
+
+// Decode with per-source defaults
+func (c *IngressConfig) UnmarshalJSON(data []byte) error {
+	type plain IngressConfig
+	p := plain{
+		Syslog: SyslogConfig{
+			Transport:      "udp",
+			ListenAddr:     "0.0.0.0:514",
+			MaxMessageSize: 1024 * 1024,
+		},
+		Tail: TailConfig{
+			CheckpointInterval: Duration(10 * time.Second),
+		},
+		HTTP: HTTPIngressConfig{
+			Path:        "/ingest",
+			MaxBodySize: 16 * 1024 * 1024,
+		},
+		Forward: ForwardConfig{
+			ListenAddr:   "127.0.0.1:24224",
+			RecordKey:    "log",
+			MaxChunkSize: 16 * 1024 * 1024,
+		},
+	}
+	err := json.Unmarshal(data, &p)
+	if err != nil {
+		return err
+	}
+	*c = IngressConfig(p)
+	return nil
 }
 
 type SyslogConfig struct {
-	Transport  string `json:"transport"`
-	ListenAddr string `json:"listen_addr"`
+	Transport      string          `json:"transport"` // udp, tcp, tls or unixgram
+	ListenAddr     string          `json:"listen_addr"`
+	TLS            SyslogTLSConfig `json:"tls"`              // Used by transport tls
+	MaxMessageSize ByteSize        `json:"max_message_size"` // Frame size limit for tcp and tls. Default 1MB
+
+	// Addresses, prefixes or hostnames allowed to send. Hostnames are resolved at startup
+	// and also match the TLS client certificate name. Empty allows all senders
+	AllowedSenders []string `json:"allowed_senders"`
+}
+
+type SyslogTLSConfig struct {
+	CertFile     string `json:"cert_file"`
+	KeyFile      string `json:"key_file"`
+	ClientCAFile string `json:"client_ca_file"` // Require client certificates signed by this CA. Optional
 }
 
 type TailConfig struct {
-	Path string `json:"path"`
-	Poll bool   `json:"poll"`
+	Path               string   `json:"path"`
+	Poll               bool     `json:"poll"`
+	CheckpointInterval Duration `json:"checkpoint_interval"` // Period of offset checkpoints. Default 10s
+}
+
+type HTTPIngressConfig struct {
+	ListenAddr  string   `json:"listen_addr"`
+	Path        string   `json:"path"`          // Default /ingest
+	Token       string   `json:"token"`         // Bearer token. Empty disables authentication
+	MaxBodySize ByteSize `json:"max_body_size"` // Default 16MB
+}
+
+type ForwardConfig struct {
+	ListenAddr   string   `json:"listen_addr"`    // Default 127.0.0.1:24224. The protocol is unauthenticated, expose it only to trusted networks
+	RecordKey    string   `json:"record_key"`     // Record field holding the log line. Empty takes whole records as JSON. Default log
+	MaxChunkSize ByteSize `json:"max_chunk_size"` // Size limit of a message, after decompression. Default 16MB
+}
+
+type RegexParserConfig struct {
+	Pattern        string `json:"pattern"`         // Named groups: client, time, server, method, url, status, sent, duration, host, agent, forwarded_for, real_ip, proxy_protocol
+	TimeLayout     string `json:"time_layout"`     // Go reference layout, or unix, unix_ms, rfc3339. Default rfc3339
+	DurationLayout string `json:"duration_layout"` // s, ms, us or go. Default s
+}
+
+type JSONParserConfig struct {
+	Fields struct {
+		Client   string `json:"client"`
+		Time     string `json:"time"`
+		Server   string `json:"server"`
+		Method   string `json:"method"`
+		URL      string `json:"url"`
+		Status   string `json:"status"`
+		Sent     string `json:"sent"`
+		Duration string `json:"duration"`
+		Host     string `json:"host"`
+		Agent    string `json:"agent"`
+
+		ForwardedFor  string `json:"forwarded_for"`
+		RealIP        string `json:"real_ip"`
+		ProxyProtocol string `json:"proxy_protocol"`
+	} `json:"fields"` // Dot separated JSON paths. Empty for the field name, except forwarding fields which are unset
+	TimeLayout     string `json:"time_layout"`     // auto, unix, unix_ms, rfc3339 or a Go reference layout. Default auto
+	DurationLayout string `json:"duration_layout"` // s, ms, us or go. Default s
 }
@@ -13,4 +13,6 @@ type MatcherConfig struct {
 	DurationMax *Duration `json:"duration_max"`
 	Host        *Regexp   `json:"host"`
 	Agent       *Regexp   `json:"agent"`
+	Tag         *string   `json:"tag"`
+	Origin      *Regexp   `json:"origin"`
 }
@@ -0,0 +1,9 @@
+package config
+
+// Buffering between ingress sources and analysis
+type PipelineConfig struct {
+	Workers    int    `json:"workers"`     // Analysis workers. Requests are sharded by client address. Default number of CPUs
+	BufferSize int    `json:"buffer_size"` // Requests held before analysis, split between workers. Default 65536
+	Overflow   string `json:"overflow"`    // block, drop_oldest, drop_newest or sample. Default block
+	SampleRate int    `json:"sample_rate"` // Used by overflow sample. One in this many requests is kept while full. Default 10
+}
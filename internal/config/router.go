@@ -0,0 +1,13 @@
+package config
+
+type RouterConfig struct {
+	Flow FlowConfig `json:"flow"`
+}
+
+// A node of the request flow tree
+type FlowConfig struct {
+	Action   string         `json:"action"`   // One of sequence, match, branch, drop, forward
+	Match    *MatcherConfig `json:"match"`    // Condition for match action and branch subflows
+	Analyzer string         `json:"analyzer"` // Target of forward action. Empty for all enabled analyzers
+	Subflow  []FlowConfig   `json:"subflow"`
+}
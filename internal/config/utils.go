@@ -1,6 +1,7 @@
 package config
 
 import (
+	"encoding/json"
 	"net/netip"
 	"regexp"
 	"strings"
@@ -28,7 +29,12 @@ type Regexp struct {
 }
 
 func (r *Regexp) UnmarshalJSON(data []byte) error {
-	s := strings.Trim(string(data), `"`)
+	// Unquote properly so that escaped backslashes reach the regexp compiler intact
+	var s string
+	err := json.Unmarshal(data, &s)
+	if err != nil {
+		return err
+	}
 
 	re, err := regexp.Compile(s)
 	if err != nil {
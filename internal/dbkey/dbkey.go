@@ -7,6 +7,9 @@ const (
 	FileSendRatio
 	RequestFrequency
 	RuleList
+	AllowList
+	ShadowRuleList
+	TailOffset
 )
 
 // Must return fixed-length slice for each type
@@ -15,17 +18,19 @@ type Object interface {
 	DBKey() []byte
 }
 
+// Builders are values shared between goroutines, e.g. a prefix kept in a struct.
+// Appending always copies, so keys built from a shared prefix never share its buffer
 type KeyBuilder struct {
 	bytes []byte
 }
 
 func (kb KeyBuilder) WithPrefix(p Prefix) KeyBuilder {
-	kb.bytes = append(kb.bytes, byte(p))
+	kb.bytes = append(kb.bytes[:len(kb.bytes):len(kb.bytes)], byte(p))
 	return kb
 }
 
 func (kb KeyBuilder) WithObject(o Object) KeyBuilder {
-	kb.bytes = append(kb.bytes, o.DBKey()...)
+	kb.bytes = append(kb.bytes[:len(kb.bytes):len(kb.bytes)], o.DBKey()...)
 	return kb
 }
 
@@ -0,0 +1,35 @@
+package dbkey
+
+import (
+	"bytes"
+	"testing"
+)
+
+type testObject string
+
+func (o testObject) DBKey() []byte {
+	return []byte(o)
+}
+
+func TestKeyBuilderSharedPrefix(t *testing.T) {
+	kb := KeyBuilder{}.WithPrefix(TailOffset)
+	web1 := kb.WithObject(testObject("web1")).Build()
+	web2 := kb.WithObject(testObject("web2")).Build()
+	nested := kb.WithPrefix(RuleList).WithObject(testObject("a")).Build()
+
+	tests := []struct {
+		name string
+		got  []byte
+		want []byte
+	}{
+		{"first key", web1, []byte("\x06web1")},
+		{"second key", web2, []byte("\x06web2")},
+		{"nested prefix", nested, []byte("\x06\x03a")},
+		{"prefix", kb.Build(), []byte("\x06")},
+	}
+	for _, tt := range tests {
+		if !bytes.Equal(tt.got, tt.want) {
+			t.Errorf("%s = %q, want %q", tt.name, tt.got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,72 @@
+package ingress
+
+import (
+	"bytes"
+	"encoding/gob"
+	"fmt"
+
+	"github.com/HT4w5/nyaago/internal/dbkey"
+	"github.com/dgraph-io/badger/v4"
+)
+
+// Position of the last line handed to the ingress worker
+type tailCheckpoint struct {
+	Source string // Ingress source name
+	Inode  uint64
+	Offset int64
+}
+
+func (c *tailCheckpoint) Marshal() ([]byte, error) {
+	var buf bytes.Buffer
+	enc := gob.NewEncoder(&buf)
+	if err := enc.Encode(c); err != nil {
+		return nil, fmt.Errorf("failed to encode checkpoint: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+func (c *tailCheckpoint) Unmarshal(data []byte) error {
+	buf := bytes.NewReader(data)
+	dec := gob.NewDecoder(buf)
+	if err := dec.Decode(c); err != nil {
+		return fmt.Errorf("failed to decode checkpoint: %w", err)
+	}
+	return nil
+}
+
+func (c tailCheckpoint) DBKey() []byte {
+	return []byte(c.Source)
+}
+
+var tailCheckpointKb = dbkey.KeyBuilder{}.WithPrefix(dbkey.TailOffset)
+
+// Load the checkpoint of a source. Returns false if there is none
+func loadTailCheckpoint(db *badger.DB, source string) (tailCheckpoint, bool, error) {
+	cp := tailCheckpoint{Source: source}
+	err := db.View(func(txn *badger.Txn) error {
+		item, err := txn.Get(tailCheckpointKb.WithObject(cp).Build())
+		if err != nil {
+			return err
+		}
+		return item.Value(func(val []byte) error {
+			return cp.Unmarshal(val)
+		})
+	})
+	if err == badger.ErrKeyNotFound {
+		return tailCheckpoint{}, false, nil
+	}
+	if err != nil {
+		return tailCheckpoint{}, false, err
+	}
+	return cp, true, nil
+}
+
+func saveTailCheckpoint(db *badger.DB, cp tailCheckpoint) error {
+	cpBytes, err := cp.Marshal()
+	if err != nil {
+		return err
+	}
+	return db.Update(func(txn *badger.Txn) error {
+		return txn.Set(tailCheckpointKb.WithObject(cp).Build(), cpBytes)
+	})
+}
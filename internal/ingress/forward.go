@@ -0,0 +1,354 @@
+package ingress
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"sync"
+
+	"github.com/HT4w5/nyaago/internal/config"
+	"github.com/HT4w5/nyaago/internal/logging"
+	"github.com/HT4w5/nyaago/internal/pipeline"
+	"github.com/HT4w5/nyaago/pkg/parser"
+	"github.com/vmihailenco/msgpack/v5"
+	"github.com/vmihailenco/msgpack/v5/msgpcode"
+)
+
+const (
+	forwardReadBufferSize = 64 * 1024
+
+	forwardOptionChunk      = "chunk"
+	forwardOptionCompressed = "compressed"
+	forwardOptionAck        = "ack"
+)
+
+var errForwardTooLarge = errors.New("forward message exceeds max_chunk_size")
+
+// Accepts events from Fluentd and Fluent Bit over the Forward protocol (v1).
+// Message, Forward, PackedForward and CompressedPackedForward modes are supported.
+// Chunks requesting an ack are acked once all their events are handed to the router
+type ForwardIngress struct {
+	sourceStats
+	cfg    *config.IngressConfig
+	parser parser.Parser
+	out    Sink
+	logger *slog.Logger
+
+	mu    sync.Mutex
+	conns map[net.Conn]struct{}
+	wg    sync.WaitGroup
+}
+
+// A decoded message. Packed entries are unpacked separately
+type forwardMessage struct {
+	tag     string
+	records []map[string]any
+	packed  []byte
+	option  map[string]any
+}
+
+func makeForwardIngress(cfg *config.IngressConfig, p parser.Parser, logger *slog.Logger) (*ForwardIngress, error) {
+	if cfg.Forward.ListenAddr == "" {
+		return nil, fmt.Errorf("listen_addr not set")
+	}
+
+	return &ForwardIngress{
+		sourceStats: sourceStats{name: cfg.Name},
+		cfg:         cfg,
+		parser:      p,
+		logger:      logger,
+		conns:       make(map[net.Conn]struct{}),
+	}, nil
+}
+
+func (i *ForwardIngress) Start(ctx context.Context, out Sink, cancel context.CancelFunc) {
+	i.out = out
+
+	i.logger.Info("starting forward server")
+	ln, err := net.Listen("tcp", i.cfg.Forward.ListenAddr)
+	if err != nil {
+		i.logger.Error("failed to start forward server", logging.SlogKeyError, err)
+		cancel()
+		return
+	}
+	i.logger.Info(fmt.Sprintf("forward server listening at tcp://%s", ln.Addr()))
+
+	go func() {
+		<-ctx.Done()
+		i.logger.Info("shutting down forward server")
+		ln.Close()
+		i.mu.Lock()
+		for conn := range i.conns {
+			conn.Close()
+		}
+		i.mu.Unlock()
+	}()
+
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			if !errors.Is(err, net.ErrClosed) {
+				i.logger.Error("forward server died", logging.SlogKeyError, err)
+				cancel()
+			}
+			break
+		}
+		i.mu.Lock()
+		i.conns[conn] = struct{}{}
+		i.mu.Unlock()
+		i.wg.Add(1)
+		go i.handleConn(ctx, conn)
+	}
+	i.wg.Wait()
+}
+
+func (i *ForwardIngress) handleConn(ctx context.Context, conn net.Conn) {
+	defer func() {
+		conn.Close()
+		i.mu.Lock()
+		delete(i.conns, conn)
+		i.mu.Unlock()
+		i.wg.Done()
+	}()
+
+	source := conn.RemoteAddr().String()
+	origin := peerOrigin(source, "")
+
+	// Bound every message. Read ahead is charged to the next message, so allow one buffer extra
+	budget := &budgetReader{r: conn}
+	dec := msgpack.NewDecoder(bufio.NewReaderSize(budget, forwardReadBufferSize))
+	maxSize := int64(i.cfg.Forward.MaxChunkSize)
+
+	for {
+		budget.n = maxSize + forwardReadBufferSize
+		msg, err := decodeForwardMessage(dec)
+		if err != nil {
+			if !errors.Is(err, io.EOF) && !errors.Is(err, net.ErrClosed) {
+				i.logger.Warn("dropping forward connection", slogKeySource, source, logging.SlogKeyError, err)
+			}
+			return
+		}
+
+		records := msg.records
+		if msg.packed != nil {
+			records, err = unpackForwardEntries(msg.packed, msg.option, maxSize)
+			if err != nil {
+				i.logger.Warn("dropping forward connection", slogKeySource, source, logging.SlogKeyError, err)
+				return
+			}
+		}
+
+		// Chunks are acked even with dropped records. Other records of the chunk
+		// are already queued and would be duplicated by a retry
+		dropped := 0
+		for _, record := range records {
+			line, ok := i.recordLine(record)
+			if !ok {
+				i.logger.Debug("record key missing", slogKeySource, source, "tag", msg.tag)
+				continue
+			}
+			req, err := i.parse(i.parser, origin, line)
+			if errors.Is(err, parser.ErrSkipLine) {
+				continue
+			}
+			if err != nil {
+				i.logger.Error("failed to parse line", slogKeyMethod, i.cfg.Method, slogKeyLogFormat, i.cfg.Format, slogKeySource, source, slogKeyLine, string(line))
+				continue
+			}
+			req.Tag = i.cfg.Tag
+			req.Origin = origin
+			switch i.out.Push(ctx, req) {
+			case pipeline.Dropped:
+				dropped++
+			case pipeline.Canceled:
+				// Unacked, the sender retries the chunk
+				return
+			}
+		}
+
+		if dropped > 0 {
+			i.logger.Warn("dropped records of chunk", slogKeySource, source, "dropped", dropped)
+		}
+		chunk, _ := msg.option[forwardOptionChunk].(string)
+		if chunk == "" {
+			continue
+		}
+		ack, err := msgpack.Marshal(map[string]string{forwardOptionAck: chunk})
+		if err != nil {
+			i.logger.Error("failed to encode ack", logging.SlogKeyError, err)
+			return
+		}
+		_, err = conn.Write(ack)
+		if err != nil {
+			i.logger.Warn("failed to send ack", slogKeySource, source, logging.SlogKeyError, err)
+			return
+		}
+	}
+}
+
+// Extract the log line of a record
+func (i *ForwardIngress) recordLine(record map[string]any) ([]byte, bool) {
+	if i.cfg.Forward.RecordKey == "" {
+		for k, v := range record {
+			if b, ok := v.([]byte); ok {
+				record[k] = string(b)
+			}
+		}
+		line, err := json.Marshal(record)
+		return line, err == nil
+	}
+
+	switch v := record[i.cfg.Forward.RecordKey].(type) {
+	case string:
+		return []byte(v), true
+	case []byte:
+		return v, true
+	default:
+		return nil, false
+	}
+}
+
+// Decode one message: [tag, time, record, option?], [tag, entries, option?] or [tag, packed, option?]
+func decodeForwardMessage(dec *msgpack.Decoder) (*forwardMessage, error) {
+	n, err := dec.DecodeArrayLen()
+	if err != nil {
+		return nil, err
+	}
+	if n < 2 || n > 4 {
+		return nil, fmt.Errorf("bad forward message length: %d", n)
+	}
+
+	msg := &forwardMessage{}
+	msg.tag, err = dec.DecodeString()
+	if err != nil {
+		return nil, fmt.Errorf("bad forward tag: %w", err)
+	}
+
+	code, err := dec.PeekCode()
+	if err != nil {
+		return nil, err
+	}
+	rest := n - 2
+	switch {
+	case msgpcode.IsFixedArray(code) || code == msgpcode.Array16 || code == msgpcode.Array32:
+		// Forward mode
+		msg.records, err = decodeForwardEntries(dec)
+	case msgpcode.IsString(code) || msgpcode.IsBin(code):
+		// PackedForward mode
+		msg.packed, err = dec.DecodeBytes()
+	default:
+		// Message mode
+		if rest == 0 {
+			return nil, fmt.Errorf("bad forward message length: %d", n)
+		}
+		var record map[string]any
+		record, err = decodeForwardEntry(dec, false)
+		msg.records = []map[string]any{record}
+		rest--
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if rest > 0 {
+		msg.option, err = dec.DecodeMap()
+		if err != nil {
+			return nil, fmt.Errorf("bad forward option: %w", err)
+		}
+	}
+	return msg, nil
+}
+
+func decodeForwardEntries(dec *msgpack.Decoder) ([]map[string]any, error) {
+	n, err := dec.DecodeArrayLen()
+	if err != nil {
+		return nil, err
+	}
+	// Lengths are untrusted, grow as entries arrive
+	records := make([]map[string]any, 0, min(n, 1024))
+	for range n {
+		record, err := decodeForwardEntry(dec, true)
+		if err != nil {
+			return nil, err
+		}
+		records = append(records, record)
+	}
+	return records, nil
+}
+
+// Decode [time, record], or time and record inline. Event time is skipped, it is taken from the log line
+func decodeForwardEntry(dec *msgpack.Decoder, wrapped bool) (map[string]any, error) {
+	if wrapped {
+		n, err := dec.DecodeArrayLen()
+		if err != nil {
+			return nil, err
+		}
+		if n != 2 {
+			return nil, fmt.Errorf("bad forward entry length: %d", n)
+		}
+	}
+	err := dec.Skip()
+	if err != nil {
+		return nil, fmt.Errorf("bad forward time: %w", err)
+	}
+	record, err := dec.DecodeMap()
+	if err != nil {
+		return nil, fmt.Errorf("bad forward record: %w", err)
+	}
+	return record, nil
+}
+
+// Decode a stream of entries from PackedForward and CompressedPackedForward modes
+func unpackForwardEntries(packed []byte, option map[string]any, maxSize int64) ([]map[string]any, error) {
+	var r io.Reader = bytes.NewReader(packed)
+	switch option[forwardOptionCompressed] {
+	case nil, "text":
+	case "gzip":
+		gz, err := gzip.NewReader(r)
+		if err != nil {
+			return nil, fmt.Errorf("bad compressed entries: %w", err)
+		}
+		defer gz.Close()
+		r = &budgetReader{r: gz, n: maxSize}
+	default:
+		return nil, fmt.Errorf("unsupported compression: %v", option[forwardOptionCompressed])
+	}
+
+	dec := msgpack.NewDecoder(r)
+	records := make([]map[string]any, 0, 64)
+	for {
+		record, err := decodeForwardEntry(dec, true)
+		if errors.Is(err, io.EOF) {
+			return records, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		records = append(records, record)
+	}
+}
+
+// Reader failing once n bytes have been read
+type budgetReader struct {
+	r io.Reader
+	n int64
+}
+
+func (b *budgetReader) Read(p []byte) (int, error) {
+	if b.n <= 0 {
+		return 0, errForwardTooLarge
+	}
+	if int64(len(p)) > b.n {
+		p = p[:b.n]
+	}
+	n, err := b.r.Read(p)
+	b.n -= int64(n)
+	return n, err
+}
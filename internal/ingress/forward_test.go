@@ -0,0 +1,257 @@
+package ingress
+
+import (
+	"bytes"
+	"log/slog"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/HT4w5/nyaago/internal/config"
+	"github.com/HT4w5/nyaago/internal/pipeline"
+	"github.com/HT4w5/nyaago/pkg/parser"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+func mustMsgpack(t *testing.T, v any) []byte {
+	t.Helper()
+	b, err := msgpack.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+// Concatenated [time, record] entries of PackedForward mode
+func packEntries(t *testing.T, records ...map[string]any) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	for _, v := range records {
+		buf.Write(mustMsgpack(t, []any{1765880734, v}))
+	}
+	return buf.Bytes()
+}
+
+// Send msgs over a connection served by i and return the acked chunks
+func forwardExchange(t *testing.T, i *ForwardIngress, msgs ...[]byte) []string {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		i.wg.Add(1)
+		i.handleConn(t.Context(), conn)
+	}()
+
+	conn, err := net.Dial("tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+	// Rejected messages reset the connection, so write and read errors end the exchange
+	for _, v := range msgs {
+		_, err := conn.Write(v)
+		if err != nil {
+			break
+		}
+	}
+	conn.(*net.TCPConn).CloseWrite()
+
+	var acks []string
+	dec := msgpack.NewDecoder(conn)
+	for {
+		var ack map[string]string
+		err := dec.Decode(&ack)
+		if err != nil {
+			break
+		}
+		acks = append(acks, ack[forwardOptionAck])
+	}
+	<-done
+	return acks
+}
+
+func TestForwardIngress(t *testing.T) {
+	record := map[string]any{"log": testNginxLine}
+	// Far over max_chunk_size once inflated, but small on the wire
+	bomb := gzipBytes(t, packEntries(t, repeatRecords(record, 1000)...))
+
+	tests := []struct {
+		name         string
+		format       string          // Default nginxcombined
+		recordKey    string          // Default log
+		wholeRecord  bool            // Empty record_key
+		maxChunkSize config.ByteSize // Default 16MB
+		result       pipeline.PushResult
+		msgs         [][]byte
+		wantPushed   int
+		wantAcks     []string
+	}{
+		{
+			name:       "message",
+			msgs:       [][]byte{mustMsgpack(t, []any{"tag", 1765880734, record})},
+			wantPushed: 1,
+		},
+		{
+			name:       "message with ack",
+			msgs:       [][]byte{mustMsgpack(t, []any{"tag", 1765880734, record, map[string]any{"chunk": "c1"}})},
+			wantPushed: 1,
+			wantAcks:   []string{"c1"},
+		},
+		{
+			name: "forward",
+			msgs: [][]byte{mustMsgpack(t, []any{"tag", []any{
+				[]any{1765880734, record},
+				[]any{1765880735, record},
+			}, map[string]any{"chunk": "c1"}})},
+			wantPushed: 2,
+			wantAcks:   []string{"c1"},
+		},
+		{
+			name: "packed forward",
+			msgs: [][]byte{
+				mustMsgpack(t, []any{"tag", packEntries(t, record, record), map[string]any{"chunk": "c1"}}),
+				mustMsgpack(t, []any{"tag", packEntries(t, record), map[string]any{"chunk": "c2"}}),
+			},
+			wantPushed: 3,
+			wantAcks:   []string{"c1", "c2"},
+		},
+		{
+			name: "compressed packed forward",
+			msgs: [][]byte{mustMsgpack(t, []any{"tag", gzipBytes(t, packEntries(t, record, record)), map[string]any{
+				"chunk":      "c1",
+				"compressed": "gzip",
+			}})},
+			wantPushed: 2,
+			wantAcks:   []string{"c1"},
+		},
+		{
+			name: "unsupported compression",
+			msgs: [][]byte{mustMsgpack(t, []any{"tag", packEntries(t, record), map[string]any{
+				"chunk":      "c1",
+				"compressed": "zstd",
+			}})},
+		},
+		{
+			name: "record key missing",
+			msgs: [][]byte{mustMsgpack(t, []any{"tag", 1765880734, map[string]any{"message": testNginxLine}, map[string]any{"chunk": "c1"}})},
+			// Nothing to retry, the chunk is still acked
+			wantAcks: []string{"c1"},
+		},
+		{
+			name:      "custom record key",
+			recordKey: "message",
+			msgs:      [][]byte{mustMsgpack(t, []any{"tag", 1765880734, map[string]any{"message": []byte(testNginxLine)}})},
+			// Binary values are accepted as lines too
+			wantPushed: 1,
+		},
+		{
+			name:        "whole record",
+			format:      "json",
+			wholeRecord: true,
+			msgs: [][]byte{mustMsgpack(t, []any{"tag", 1765880734, map[string]any{
+				"client": []byte("192.0.2.1"),
+				"time":   1765880734,
+				"sent":   1234,
+			}})},
+			wantPushed: 1,
+		},
+		{
+			name:   "dropped records are acked",
+			result: pipeline.Dropped,
+			msgs:   [][]byte{mustMsgpack(t, []any{"tag", packEntries(t, record, record), map[string]any{"chunk": "c1"}})},
+			// A retry would resend the records queued before the drop
+			wantAcks: []string{"c1"},
+		},
+		{
+			name:         "message too large",
+			maxChunkSize: 1024,
+			msgs: [][]byte{mustMsgpack(t, []any{"tag", packEntries(t, repeatRecords(record, 1000)...), map[string]any{
+				"chunk": "c1",
+			}})},
+		},
+		{
+			name:         "decompressed message too large",
+			maxChunkSize: 1024,
+			msgs: [][]byte{mustMsgpack(t, []any{"tag", bomb, map[string]any{
+				"chunk":      "c1",
+				"compressed": "gzip",
+			}})},
+		},
+		{
+			name: "bad message",
+			msgs: [][]byte{
+				mustMsgpack(t, []any{"tag"}),
+				mustMsgpack(t, []any{"tag", 1765880734, record}),
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			format := tt.format
+			if format == "" {
+				format = "nginxcombined"
+			}
+			p, err := parser.MakeParser(format, parser.Options{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			cfg := &config.IngressConfig{Name: "test", Method: "forward", Format: format}
+			cfg.Forward.ListenAddr = "127.0.0.1:0"
+			cfg.Forward.RecordKey = "log"
+			if tt.recordKey != "" {
+				cfg.Forward.RecordKey = tt.recordKey
+			}
+			if tt.wholeRecord {
+				cfg.Forward.RecordKey = ""
+			}
+			cfg.Forward.MaxChunkSize = 16 * 1024 * 1024
+			if tt.maxChunkSize != 0 {
+				cfg.Forward.MaxChunkSize = tt.maxChunkSize
+			}
+			i, err := makeForwardIngress(cfg, p, slog.New(slog.DiscardHandler))
+			if err != nil {
+				t.Fatal(err)
+			}
+			sink := &testSink{result: tt.result}
+			i.out = sink
+
+			acks := forwardExchange(t, i, tt.msgs...)
+			if sink.len() != tt.wantPushed {
+				t.Errorf("pushed = %d, want %d", sink.len(), tt.wantPushed)
+			}
+			if len(acks) != len(tt.wantAcks) {
+				t.Fatalf("acks = %v, want %v", acks, tt.wantAcks)
+			}
+			for k, v := range tt.wantAcks {
+				if acks[k] != v {
+					t.Errorf("ack %d = %q, want %q", k, acks[k], v)
+				}
+			}
+			for _, v := range sink.reqs {
+				if v.Origin != "127.0.0.1" {
+					t.Errorf("origin = %q, want the peer address", v.Origin)
+				}
+			}
+		})
+	}
+}
+
+func repeatRecords(record map[string]any, n int) []map[string]any {
+	records := make([]map[string]any, n)
+	for k := range records {
+		records[k] = record
+	}
+	return records
+}
@@ -0,0 +1,230 @@
+package ingress
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"mime"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/HT4w5/nyaago/internal/config"
+	"github.com/HT4w5/nyaago/internal/logging"
+	"github.com/HT4w5/nyaago/internal/pipeline"
+	"github.com/HT4w5/nyaago/pkg/dto"
+	"github.com/HT4w5/nyaago/pkg/parser"
+)
+
+const (
+	httpShutdownTimeout = 5 * time.Second
+	httpMaxLineSize     = 1024 * 1024
+)
+
+// Accepts batches of log lines POSTed by log shippers.
+// Bodies are JSON arrays if sent as application/json, otherwise newline delimited
+type HTTPIngress struct {
+	sourceStats
+	cfg    *config.IngressConfig
+	parser parser.Parser
+	srv    *http.Server
+	out    Sink
+	logger *slog.Logger
+}
+
+func makeHTTPIngress(cfg *config.IngressConfig, p parser.Parser, logger *slog.Logger) (*HTTPIngress, error) {
+	if cfg.HTTP.ListenAddr == "" {
+		return nil, fmt.Errorf("listen_addr not set")
+	}
+
+	i := &HTTPIngress{
+		sourceStats: sourceStats{name: cfg.Name},
+		cfg:         cfg,
+		parser:      p,
+		logger:      logger,
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST "+cfg.HTTP.Path, i.handleBatch)
+	i.srv = &http.Server{
+		Addr:              cfg.HTTP.ListenAddr,
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+		ErrorLog:          slog.NewLogLogger(logger.Handler(), slog.LevelWarn),
+	}
+
+	return i, nil
+}
+
+func (i *HTTPIngress) Start(ctx context.Context, out Sink, cancel context.CancelFunc) {
+	i.out = out
+	i.srv.BaseContext = func(net.Listener) context.Context { return ctx }
+
+	i.logger.Info("starting http server")
+	ln, err := net.Listen("tcp", i.cfg.HTTP.ListenAddr)
+	if err != nil {
+		i.logger.Error("failed to start http server", logging.SlogKeyError, err)
+		cancel()
+		return
+	}
+	i.logger.Info(fmt.Sprintf("http server listening at http://%s%s", ln.Addr(), i.cfg.HTTP.Path))
+
+	shutdownDone := make(chan struct{})
+	go func() {
+		defer close(shutdownDone)
+		<-ctx.Done()
+		i.logger.Info("shutting down http server")
+		shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), httpShutdownTimeout)
+		defer shutdownCancel()
+		if err := i.srv.Shutdown(shutdownCtx); err != nil {
+			i.logger.Error("failed to shutdown http server", logging.SlogKeyError, err)
+		}
+	}()
+
+	err = i.srv.Serve(ln)
+	if err != nil && !errors.Is(err, http.ErrServerClosed) {
+		i.logger.Error("http server died", logging.SlogKeyError, err)
+		cancel()
+	}
+	// Serve returns before in-flight batches are done. They must finish pushing
+	// before the adapter counts as stopped
+	<-shutdownDone
+}
+
+func (i *HTTPIngress) handleBatch(w http.ResponseWriter, r *http.Request) {
+	if !i.authorized(r) {
+		w.Header().Set("WWW-Authenticate", "Bearer")
+		writeJSON(w, http.StatusUnauthorized, dto.ErrorJSON{Error: "unauthorized"})
+		return
+	}
+
+	maxSize := int64(i.cfg.HTTP.MaxBodySize)
+	var body io.Reader = http.MaxBytesReader(w, r.Body, maxSize)
+	if strings.EqualFold(r.Header.Get("Content-Encoding"), "gzip") {
+		gz, err := gzip.NewReader(body)
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, dto.MakeErrorJSON(err))
+			return
+		}
+		defer gz.Close()
+		// Limit the decompressed size too, a small body can inflate enormously
+		body = http.MaxBytesReader(w, gz, maxSize)
+	}
+
+	var lines [][]byte
+	var err error
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if mediaType == "application/json" {
+		lines, err = readJSONBatch(body)
+	} else {
+		lines, err = readLineBatch(body)
+	}
+	if err != nil {
+		status := http.StatusBadRequest
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			status = http.StatusRequestEntityTooLarge
+		}
+		writeJSON(w, status, dto.MakeErrorJSON(err))
+		return
+	}
+
+	var res dto.IngressBatchJSON
+	source := r.RemoteAddr
+	origin := peerOrigin(source, "")
+	for _, line := range lines {
+		req, err := i.parse(i.parser, origin, line)
+		if errors.Is(err, parser.ErrSkipLine) {
+			continue
+		}
+		if err != nil {
+			i.logger.Error("failed to parse line", slogKeyMethod, i.cfg.Method, slogKeyLogFormat, i.cfg.Format, slogKeySource, source, slogKeyLine, string(line))
+			res.Rejected++
+			continue
+		}
+		req.Tag = i.cfg.Tag
+		req.Origin = origin
+		switch i.out.Push(r.Context(), req) {
+		case pipeline.Queued:
+			res.Accepted++
+		case pipeline.Dropped:
+			res.Dropped++
+		case pipeline.Canceled:
+			// Client gone or shutting down, report nothing
+			return
+		}
+	}
+
+	// The rest of the batch is queued, so a failure status would make shippers send it twice.
+	// Drops are reported in the body instead
+	if res.Dropped > 0 {
+		i.logger.Warn("dropped lines of batch", slogKeySource, source, "dropped", res.Dropped)
+	}
+	writeJSON(w, http.StatusOK, res)
+}
+
+func (i *HTTPIngress) authorized(r *http.Request) bool {
+	if i.cfg.HTTP.Token == "" {
+		return true
+	}
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(token), []byte(i.cfg.HTTP.Token)) == 1
+}
+
+// Read a JSON array. String elements are taken as lines, other elements as their JSON encoding
+func readJSONBatch(body io.Reader) ([][]byte, error) {
+	var batch []json.RawMessage
+	err := json.NewDecoder(body).Decode(&batch)
+	if err != nil {
+		return nil, fmt.Errorf("bad json batch: %w", err)
+	}
+
+	lines := make([][]byte, 0, len(batch))
+	for _, v := range batch {
+		v = bytes.TrimSpace(v)
+		if len(v) > 0 && v[0] == '"' {
+			var s string
+			if err := json.Unmarshal(v, &s); err != nil {
+				return nil, fmt.Errorf("bad json batch: %w", err)
+			}
+			v = []byte(s)
+		}
+		lines = append(lines, v)
+	}
+	return lines, nil
+}
+
+// Read newline delimited lines, skipping blank ones
+func readLineBatch(body io.Reader) ([][]byte, error) {
+	lines := make([][]byte, 0, 64)
+	sc := bufio.NewScanner(body)
+	sc.Buffer(make([]byte, 0, 64*1024), httpMaxLineSize)
+	for sc.Scan() {
+		line := bytes.TrimRight(sc.Bytes(), "\r")
+		if len(bytes.TrimSpace(line)) == 0 {
+			continue
+		}
+		lines = append(lines, bytes.Clone(line))
+	}
+	if err := sc.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read batch: %w", err)
+	}
+	return lines, nil
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,189 @@
+package ingress
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/HT4w5/nyaago/internal/config"
+	"github.com/HT4w5/nyaago/internal/pipeline"
+	"github.com/HT4w5/nyaago/pkg/dto"
+	"github.com/HT4w5/nyaago/pkg/parser"
+)
+
+const testNginxLine = `192.0.2.1 - - [16/Dec/2025:10:25:34 +0000] "GET / HTTP/1.1" 200 1234 "-" "curl/8.5.0"`
+
+// Sink collecting pushed requests
+type testSink struct {
+	mu     sync.Mutex
+	reqs   []dto.Request
+	result pipeline.PushResult // Returned by every push. Only queued requests are collected
+}
+
+func (s *testSink) Push(ctx context.Context, req dto.Request) pipeline.PushResult {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.result == pipeline.Queued {
+		s.reqs = append(s.reqs, req)
+	}
+	return s.result
+}
+
+func (s *testSink) len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.reqs)
+}
+
+func gzipBytes(t *testing.T, data []byte) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	_, err := gz.Write(data)
+	if err == nil {
+		err = gz.Close()
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestHTTPIngressBatch(t *testing.T) {
+	const maxBodySize = 4096
+	lines := strings.Repeat(testNginxLine+"\n", 3)
+	// Far over max_body_size once inflated, but small on the wire
+	bomb := gzipBytes(t, bytes.Repeat([]byte(testNginxLine+"\n"), 1000))
+	if len(bomb) >= maxBodySize {
+		t.Fatalf("compressed bomb is %d bytes, want less than %d", len(bomb), maxBodySize)
+	}
+
+	tests := []struct {
+		name         string
+		body         []byte
+		gzip         bool
+		token        string
+		contentType  string
+		result       pipeline.PushResult
+		wantCode     int
+		wantAccepted int
+		wantDropped  int
+	}{
+		{
+			name:         "lines",
+			body:         []byte(lines),
+			wantCode:     http.StatusOK,
+			wantAccepted: 3,
+		},
+		{
+			name:         "json",
+			body:         []byte(`["` + strings.ReplaceAll(testNginxLine, `"`, `\"`) + `"]`),
+			contentType:  "application/json",
+			wantCode:     http.StatusOK,
+			wantAccepted: 1,
+		},
+		{
+			name:         "gzip",
+			body:         gzipBytes(t, []byte(lines)),
+			gzip:         true,
+			wantCode:     http.StatusOK,
+			wantAccepted: 3,
+		},
+		{
+			name:        "dropped lines",
+			body:        []byte(lines),
+			result:      pipeline.Dropped,
+			wantCode:    http.StatusOK,
+			wantDropped: 3,
+		},
+		{
+			name:     "body too large",
+			body:     bytes.Repeat([]byte(testNginxLine+"\n"), 100),
+			wantCode: http.StatusRequestEntityTooLarge,
+		},
+		{
+			name:     "decompressed body too large",
+			body:     bomb,
+			gzip:     true,
+			wantCode: http.StatusRequestEntityTooLarge,
+		},
+		{
+			name:     "bad gzip",
+			body:     []byte(lines),
+			gzip:     true,
+			wantCode: http.StatusBadRequest,
+		},
+		{
+			name:     "unauthorized",
+			body:     []byte(lines),
+			token:    "wrong",
+			wantCode: http.StatusUnauthorized,
+		},
+	}
+
+	p, err := parser.MakeParser("nginxcombined", parser.Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &config.IngressConfig{Name: "test"}
+			cfg.HTTP.ListenAddr = "127.0.0.1:0"
+			cfg.HTTP.Path = "/ingest"
+			cfg.HTTP.Token = "secret"
+			cfg.HTTP.MaxBodySize = maxBodySize
+			i, err := makeHTTPIngress(cfg, p, slog.New(slog.DiscardHandler))
+			if err != nil {
+				t.Fatal(err)
+			}
+			sink := &testSink{result: tt.result}
+			i.out = sink
+
+			req := httptest.NewRequest(http.MethodPost, "/ingest", bytes.NewReader(tt.body))
+			token := tt.token
+			if token == "" {
+				token = cfg.HTTP.Token
+			}
+			req.Header.Set("Authorization", "Bearer "+token)
+			if tt.gzip {
+				req.Header.Set("Content-Encoding", "gzip")
+			}
+			if tt.contentType != "" {
+				req.Header.Set("Content-Type", tt.contentType)
+			}
+			res := httptest.NewRecorder()
+			i.srv.Handler.ServeHTTP(res, req)
+
+			if res.Code != tt.wantCode {
+				t.Fatalf("status = %d, want %d: %s", res.Code, tt.wantCode, res.Body.String())
+			}
+			if res.Code != http.StatusOK {
+				if sink.len() != 0 {
+					t.Errorf("%d requests pushed from a failed batch", sink.len())
+				}
+				return
+			}
+			var batch dto.IngressBatchJSON
+			err = json.Unmarshal(res.Body.Bytes(), &batch)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if batch.Accepted != tt.wantAccepted || sink.len() != tt.wantAccepted {
+				t.Errorf("accepted = %d, pushed = %d, want %d", batch.Accepted, sink.len(), tt.wantAccepted)
+			}
+			if batch.Dropped != tt.wantDropped {
+				t.Errorf("dropped = %d, want %d", batch.Dropped, tt.wantDropped)
+			}
+			if sink.len() > 0 && sink.reqs[0].Origin != "192.0.2.1" {
+				t.Errorf("origin = %q, want the peer address", sink.reqs[0].Origin)
+			}
+		})
+	}
+}
@@ -3,17 +3,21 @@ package ingress
 import (
 	"context"
 	"fmt"
+	"net/netip"
 
 	"github.com/HT4w5/nyaago/internal/config"
 	"github.com/HT4w5/nyaago/internal/logging"
+	"github.com/HT4w5/nyaago/internal/pipeline"
 	"github.com/HT4w5/nyaago/pkg/dto"
 	"github.com/HT4w5/nyaago/pkg/parser"
+	"github.com/dgraph-io/badger/v4"
 )
 
 const (
 	slogModuleName = "ingress"
 	slogGroupName  = "ingress"
 
+	slogKeyName      = "name"
 	slogKeyLogFormat = "log_format"
 	slogKeySource    = "source"
 	slogKeyMethod    = "method"
@@ -21,29 +25,29 @@ const (
 )
 
 type IngressAdapter interface {
-	Start(ctx context.Context, out chan<- dto.Request, cancel context.CancelFunc)
+	Start(ctx context.Context, out Sink, cancel context.CancelFunc)
+	Stats() dto.IngressSourceStatsJSON
 }
 
-func MakeIngressAdapter(cfg *config.IngressConfig) (IngressAdapter, error) {
+// Destination of parsed requests
+type Sink interface {
+	// Queue a request. Only requests reported as queued may be acknowledged to senders
+	Push(ctx context.Context, req dto.Request) pipeline.PushResult
+}
+
+func MakeIngressAdapter(cfg *config.IngressConfig, db *badger.DB) (IngressAdapter, error) {
 	// Setup parser
-	var p parser.Parser
-	switch cfg.Format {
-	case "nginxjson":
-		var err error
-		p, err = parser.MakeParser(cfg.Format)
-		if err != nil {
-			return nil, err
-		}
-	default:
-		return nil, fmt.Errorf("unsupported ingress log format: %s", cfg.Format)
+	p, err := MakeParser(cfg)
+	if err != nil {
+		return nil, err
 	}
 
 	// Setup logger
-	logger := logging.GetLogger().With(logging.SlogKeyModule, slogModuleName).WithGroup(slogGroupName)
+	logger := logging.GetLogger().With(logging.SlogKeyModule, slogModuleName).WithGroup(slogGroupName).With(slogKeyName, cfg.Name)
 
 	switch cfg.Method {
 	case "tail":
-		ti, err := makeTailIngress(cfg, p, logger)
+		ti, err := makeTailIngress(cfg, p, db, logger)
 		if err != nil {
 			return nil, fmt.Errorf("failed to create tail ingress: %w", err)
 		}
@@ -54,7 +58,64 @@ func MakeIngressAdapter(cfg *config.IngressConfig) (IngressAdapter, error) {
 			return nil, fmt.Errorf("failed to create syslog ingress: %w", err)
 		}
 		return ti, nil
+	case "http":
+		hi, err := makeHTTPIngress(cfg, p, logger)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create http ingress: %w", err)
+		}
+		return hi, nil
+	case "forward":
+		fi, err := makeForwardIngress(cfg, p, logger)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create forward ingress: %w", err)
+		}
+		return fi, nil
 	default:
 		return nil, fmt.Errorf("unsupported ingress method: %s", cfg.Method)
 	}
 }
+
+// Create adapters for all sources
+func MakeIngressAdapters(cfgs []config.IngressConfig, db *badger.DB) ([]IngressAdapter, error) {
+	adapters := make([]IngressAdapter, 0, len(cfgs))
+	for i := range cfgs {
+		ia, err := MakeIngressAdapter(&cfgs[i], db)
+		if err != nil {
+			return nil, fmt.Errorf("ingress source %s: %w", cfgs[i].Name, err)
+		}
+		adapters = append(adapters, ia)
+	}
+	return adapters, nil
+}
+
+// Create the parser configured for a source
+func MakeParser(cfg *config.IngressConfig) (parser.Parser, error) {
+	p, err := parser.MakeParser(cfg.Format, parserOptions(cfg))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create parser: %w", err)
+	}
+	return p, nil
+}
+
+func parserOptions(cfg *config.IngressConfig) parser.Options {
+	trusted := make([]netip.Prefix, len(cfg.TrustedProxies))
+	for i, p := range cfg.TrustedProxies {
+		trusted[i] = p.Prefix
+	}
+
+	return parser.Options{
+		Regex: parser.RegexOptions{
+			Pattern:        cfg.Regex.Pattern,
+			TimeLayout:     cfg.Regex.TimeLayout,
+			DurationLayout: cfg.Regex.DurationLayout,
+		},
+		JSON: parser.JSONOptions{
+			Fields:         parser.JSONFields(cfg.JSON.Fields),
+			TimeLayout:     cfg.JSON.TimeLayout,
+			DurationLayout: cfg.JSON.DurationLayout,
+		},
+		Proxy: parser.ProxyOptions{
+			Trusted: trusted,
+		},
+	}
+}
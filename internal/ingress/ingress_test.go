@@ -0,0 +1,79 @@
+//go:build unix
+
+package ingress
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/HT4w5/nyaago/internal/config"
+	"github.com/HT4w5/nyaago/internal/logging"
+	"github.com/dgraph-io/badger/v4"
+)
+
+// Two sources feed one sink, each stamping its own tag
+func TestIngressSourcesTags(t *testing.T) {
+	err := logging.Init(&config.LogConfig{Access: "none"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	db, err := badger.Open(badger.DefaultOptions("").WithInMemory(true).WithLogger(nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	// Each source logs its own client
+	clients := map[string]string{"edge": "192.0.2.1", "origin": "198.51.100.1"}
+	dir := t.TempDir()
+	var cfgs []config.IngressConfig
+	for tag, client := range clients {
+		path := filepath.Join(dir, tag+".log")
+		line := strings.Replace(testNginxLine, "192.0.2.1", client, 1)
+		inode := writeTestFile(t, path, line+"\n"+line+"\n")
+		// Read the files from the start
+		err := saveTailCheckpoint(db, tailCheckpoint{Source: tag, Inode: inode})
+		if err != nil {
+			t.Fatal(err)
+		}
+		cfg := config.IngressConfig{Name: tag, Tag: tag, Method: "tail", Format: "nginxcombined"}
+		cfg.Tail.Path = path
+		cfg.Tail.CheckpointInterval = config.Duration(time.Second)
+		cfgs = append(cfgs, cfg)
+	}
+
+	adapters, err := MakeIngressAdapters(cfgs, db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	sink := &testSink{}
+	var wg sync.WaitGroup
+	for _, ia := range adapters {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ia.Start(ctx, sink, cancel)
+		}()
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for sink.len() < 4 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	cancel()
+	wg.Wait()
+
+	if sink.len() != 4 {
+		t.Fatalf("pushed = %d, want 4", sink.len())
+	}
+	for _, req := range sink.reqs {
+		if clients[req.Tag] != req.Client.String() {
+			t.Errorf("request from %s tagged %q", req.Client, req.Tag)
+		}
+	}
+}
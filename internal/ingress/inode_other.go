@@ -0,0 +1,10 @@
+//go:build !unix
+
+package ingress
+
+import "errors"
+
+// Inodes are unavailable, so tail always starts at the end of the file
+func statInode(path string) (uint64, int64, error) {
+	return 0, 0, errors.New("inodes not supported on this platform")
+}
@@ -0,0 +1,22 @@
+//go:build unix
+
+package ingress
+
+import (
+	"fmt"
+	"os"
+	"syscall"
+)
+
+// Get inode number and size of a file
+func statInode(path string) (uint64, int64, error) {
+	fi, err := os.Stat(path)
+	if err != nil {
+		return 0, 0, err
+	}
+	st, ok := fi.Sys().(*syscall.Stat_t)
+	if !ok {
+		return 0, 0, fmt.Errorf("no inode for %s", path)
+	}
+	return uint64(st.Ino), fi.Size(), nil
+}
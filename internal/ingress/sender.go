@@ -0,0 +1,183 @@
+package ingress
+
+import (
+	"log/slog"
+	"net"
+	"net/netip"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/HT4w5/nyaago/internal/logging"
+	"github.com/HT4w5/nyaago/pkg/dto"
+	"gopkg.in/mcuadros/go-syslog.v2/format"
+)
+
+// Rejected senders kept for reporting. Peer addresses of datagrams can be spoofed,
+// so the least recently seen sender is evicted beyond this
+const maxSenderRejections = 1024
+
+// Reports log lines rejected by sender checks
+type RejectionReporter interface {
+	Rejections() []dto.IngressRejectionJSON
+}
+
+type senderRejection struct {
+	hostname string
+	count    uint64
+	lastSeen time.Time
+}
+
+// Allowed syslog senders. Membership is decided by the peer address or TLS client name,
+// never by the hostname in the message header which any sender can forge
+type senderFilter struct {
+	source   string
+	prefixes []netip.Prefix
+	names    []string
+	logger   *slog.Logger
+
+	mu       sync.Mutex
+	rejected map[string]*senderRejection // Keyed by peer address
+}
+
+// Create a filter from address, prefix or hostname entries. Returns nil if entries is empty
+func makeSenderFilter(source string, entries []string, logger *slog.Logger) *senderFilter {
+	if len(entries) == 0 {
+		return nil
+	}
+
+	f := &senderFilter{
+		source:   source,
+		logger:   logger,
+		rejected: make(map[string]*senderRejection),
+	}
+	for _, v := range entries {
+		if prefix, err := netip.ParsePrefix(v); err == nil {
+			f.prefixes = append(f.prefixes, prefix.Masked())
+			continue
+		}
+		if addr, err := netip.ParseAddr(v); err == nil {
+			f.prefixes = append(f.prefixes, netip.PrefixFrom(addr.Unmap(), addr.Unmap().BitLen()))
+			continue
+		}
+
+		f.names = append(f.names, strings.ToLower(v))
+		addrs, err := net.LookupHost(v)
+		if err != nil {
+			logger.Warn("failed to resolve allowed sender", "sender", v, logging.SlogKeyError, err)
+			continue
+		}
+		for _, a := range addrs {
+			if addr, err := netip.ParseAddr(a); err == nil {
+				f.prefixes = append(f.prefixes, netip.PrefixFrom(addr.Unmap(), addr.Unmap().BitLen()))
+			}
+		}
+	}
+	return f
+}
+
+// Check a message against the filter, recording rejections. A nil filter allows all
+func (f *senderFilter) allow(parts format.LogParts) bool {
+	if f == nil {
+		return true
+	}
+
+	client, _ := parts[logPartKeyClient].(string)
+	if client == "" {
+		// Local unixgram socket
+		return true
+	}
+	if peer, _ := parts[logPartKeyTLSPeer].(string); peer != "" {
+		for _, v := range f.names {
+			if strings.EqualFold(v, peer) {
+				return true
+			}
+		}
+	}
+
+	addr, err := netip.ParseAddrPort(client)
+	if err == nil {
+		for _, v := range f.prefixes {
+			if v.Contains(addr.Addr().Unmap()) {
+				return true
+			}
+		}
+	}
+
+	sender := client
+	if err == nil {
+		sender = addr.Addr().Unmap().String()
+	}
+	hostname, _ := parts[logPartKeyHostname].(string)
+
+	f.mu.Lock()
+	rec, seen := f.rejected[sender]
+	if !seen {
+		if len(f.rejected) >= maxSenderRejections {
+			f.evictOldestLocked()
+		}
+		rec = &senderRejection{}
+		f.rejected[sender] = rec
+	}
+	rec.hostname = hostname
+	rec.count++
+	rec.lastSeen = time.Now()
+	f.mu.Unlock()
+
+	// Only log the first rejection of a sender to avoid flooding
+	if !seen {
+		f.logger.Warn("rejected message from unknown sender", "sender", sender, "hostname", hostname)
+	}
+	return false
+}
+
+func (f *senderFilter) evictOldestLocked() {
+	var oldest string
+	var oldestSeen time.Time
+	for k, v := range f.rejected {
+		if oldest == "" || v.lastSeen.Before(oldestSeen) {
+			oldest = k
+			oldestSeen = v.lastSeen
+		}
+	}
+	delete(f.rejected, oldest)
+}
+
+// Origin stamped on requests. Prefers the verified TLS client name, then the peer
+// address without port. Empty for local sockets
+func peerOrigin(addr string, tlsPeer string) string {
+	if tlsPeer != "" {
+		return tlsPeer
+	}
+	if addrPort, err := netip.ParseAddrPort(addr); err == nil {
+		return addrPort.Addr().Unmap().String()
+	}
+	if host, _, err := net.SplitHostPort(addr); err == nil {
+		return host
+	}
+	return addr
+}
+
+func (f *senderFilter) rejections() []dto.IngressRejectionJSON {
+	if f == nil {
+		return []dto.IngressRejectionJSON{}
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	res := make([]dto.IngressRejectionJSON, 0, len(f.rejected))
+	for k, v := range f.rejected {
+		res = append(res, dto.IngressRejectionJSON{
+			Source:   f.source,
+			Sender:   k,
+			Hostname: v.hostname,
+			Count:    v.count,
+			LastSeen: v.lastSeen.Format(time.RFC3339),
+		})
+	}
+	slices.SortFunc(res, func(a, b dto.IngressRejectionJSON) int {
+		return strings.Compare(a.Sender, b.Sender)
+	})
+	return res
+}
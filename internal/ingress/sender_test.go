@@ -0,0 +1,150 @@
+package ingress
+
+import (
+	"fmt"
+	"log/slog"
+	"testing"
+
+	"gopkg.in/mcuadros/go-syslog.v2/format"
+)
+
+func TestSenderFilter(t *testing.T) {
+	entries := []string{"192.0.2.0/24", "2001:db8::1", "localhost"}
+	tests := []struct {
+		name    string
+		client  string
+		tlsPeer string
+		want    bool
+	}{
+		{
+			name:   "in prefix",
+			client: "192.0.2.7:51234",
+			want:   true,
+		},
+		{
+			name:   "mapped address in prefix",
+			client: "[::ffff:192.0.2.7]:51234",
+			want:   true,
+		},
+		{
+			name:   "address",
+			client: "[2001:db8::1]:514",
+			want:   true,
+		},
+		{
+			name:   "resolved hostname",
+			client: "127.0.0.1:51234",
+			want:   true,
+		},
+		{
+			name:    "tls peer name",
+			client:  "198.51.100.7:51234",
+			tlsPeer: "LOCALHOST",
+			want:    true,
+		},
+		{
+			name:   "local socket",
+			client: "",
+			want:   true,
+		},
+		{
+			name:   "unknown",
+			client: "198.51.100.7:51234",
+			want:   false,
+		},
+		{
+			name:    "unknown tls peer",
+			client:  "198.51.100.7:51234",
+			tlsPeer: "web1.example.com",
+			want:    false,
+		},
+	}
+
+	f := makeSenderFilter("test", entries, slog.New(slog.DiscardHandler))
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			parts := format.LogParts{
+				logPartKeyClient:   tt.client,
+				logPartKeyHostname: "forged",
+			}
+			if tt.tlsPeer != "" {
+				parts[logPartKeyTLSPeer] = tt.tlsPeer
+			}
+			got := f.allow(parts)
+			if got != tt.want {
+				t.Errorf("allow() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	rejections := f.rejections()
+	if len(rejections) != 1 || rejections[0].Sender != "198.51.100.7" || rejections[0].Count != 2 || rejections[0].Hostname != "forged" {
+		t.Errorf("rejections() = %+v, want one sender 198.51.100.7 rejected twice", rejections)
+	}
+
+	var nilFilter *senderFilter
+	if !nilFilter.allow(format.LogParts{logPartKeyClient: "198.51.100.7:51234"}) {
+		t.Error("nil filter rejected a sender")
+	}
+	if makeSenderFilter("test", nil, slog.New(slog.DiscardHandler)) != nil {
+		t.Error("makeSenderFilter() without entries is not nil")
+	}
+}
+
+func TestSenderFilterBounded(t *testing.T) {
+	f := makeSenderFilter("test", []string{"192.0.2.0/24"}, slog.New(slog.DiscardHandler))
+	for i := range maxSenderRejections + 100 {
+		client := fmt.Sprintf("[2001:db8::%x]:514", i)
+		if f.allow(format.LogParts{logPartKeyClient: client}) {
+			t.Fatalf("allow(%s) = true", client)
+		}
+	}
+	if n := len(f.rejections()); n != maxSenderRejections {
+		t.Errorf("len(rejections()) = %d, want %d", n, maxSenderRejections)
+	}
+}
+
+func TestPeerOrigin(t *testing.T) {
+	tests := []struct {
+		name    string
+		addr    string
+		tlsPeer string
+		want    string
+	}{
+		{
+			name: "ipv4",
+			addr: "192.0.2.7:51234",
+			want: "192.0.2.7",
+		},
+		{
+			name: "ipv4 mapped",
+			addr: "[::ffff:192.0.2.7]:51234",
+			want: "192.0.2.7",
+		},
+		{
+			name: "ipv6",
+			addr: "[2001:db8::1]:514",
+			want: "2001:db8::1",
+		},
+		{
+			name:    "tls peer",
+			addr:    "192.0.2.7:51234",
+			tlsPeer: "web1.example.com",
+			want:    "web1.example.com",
+		},
+		{
+			name: "local socket",
+			addr: "",
+			want: "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := peerOrigin(tt.addr, tt.tlsPeer)
+			if got != tt.want {
+				t.Errorf("peerOrigin() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,47 @@
+package ingress
+
+import (
+	"errors"
+	"sync/atomic"
+
+	"github.com/HT4w5/nyaago/pkg/dto"
+	"github.com/HT4w5/nyaago/pkg/parser"
+)
+
+// Line counters of a source, embedded in adapters
+type sourceStats struct {
+	name        string
+	received    atomic.Uint64
+	parsed      atomic.Uint64
+	parseFailed atomic.Uint64
+}
+
+func (s *sourceStats) Stats() dto.IngressSourceStatsJSON {
+	return dto.IngressSourceStatsJSON{
+		Source:      s.name,
+		Received:    s.received.Load(),
+		Parsed:      s.parsed.Load(),
+		ParseFailed: s.parseFailed.Load(),
+	}
+}
+
+// Parse a line sent by origin, counting the outcome
+func (s *sourceStats) parse(p parser.Parser, origin string, line []byte) (dto.Request, error) {
+	s.received.Add(1)
+	var req dto.Request
+	var err error
+	if op, ok := p.(parser.OriginParser); ok {
+		req, err = op.ParseFrom(origin, line)
+	} else {
+		req, err = p.Parse(line)
+	}
+	if errors.Is(err, parser.ErrSkipLine) {
+		return req, err
+	}
+	if err != nil {
+		s.parseFailed.Add(1)
+		return req, err
+	}
+	s.parsed.Add(1)
+	return req, nil
+}
@@ -2,6 +2,7 @@ package ingress
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log/slog"
 
@@ -19,19 +20,29 @@ const (
 )
 
 type SyslogIngress struct {
+	sourceStats
 	cfg    *config.IngressConfig
 	parser parser.Parser
-	srv    *syslog.Server
+	srv    *syslog.Server      // Datagram transports
+	stream *syslogStreamServer // Stream transports
 	out    syslog.LogPartsChannel
+	filter *senderFilter
 	logger *slog.Logger
 }
 
 func makeSyslogIngress(cfg *config.IngressConfig, p parser.Parser, logger *slog.Logger) (*SyslogIngress, error) {
 	i := &SyslogIngress{
-		cfg:    cfg,
-		parser: p,
-		logger: logger,
-		out:    make(syslog.LogPartsChannel),
+		sourceStats: sourceStats{name: cfg.Name},
+		cfg:         cfg,
+		parser:      p,
+		logger:      logger,
+		out:         make(syslog.LogPartsChannel),
+		filter:      makeSenderFilter(cfg.Name, cfg.Syslog.AllowedSenders, logger),
+	}
+
+	// Stream transports use our own framing
+	if isStreamTransport(cfg.Syslog.Transport) {
+		return i, nil
 	}
 
 	// Setup syslog server
@@ -40,8 +51,6 @@ func makeSyslogIngress(cfg *config.IngressConfig, p parser.Parser, logger *slog.
 	i.srv.SetFormat(syslog.Automatic)
 	i.srv.SetHandler(handler)
 	switch cfg.Syslog.Transport {
-	case "tcp":
-		i.srv.ListenTCP(cfg.Syslog.ListenAddr)
 	case "udp":
 		i.srv.ListenUDP(cfg.Syslog.ListenAddr)
 	case "unixgram":
@@ -53,10 +62,10 @@ func makeSyslogIngress(cfg *config.IngressConfig, p parser.Parser, logger *slog.
 	return i, nil
 }
 
-func (i *SyslogIngress) Start(ctx context.Context, out chan<- dto.Request, cancel context.CancelFunc) {
+func (i *SyslogIngress) Start(ctx context.Context, out Sink, cancel context.CancelFunc) {
 	// Start syslog server
 	i.logger.Info("starting syslog server")
-	err := i.srv.Boot()
+	err := i.boot(ctx, cancel)
 	if err != nil {
 		i.logger.Error("failed to start syslog server", logging.SlogKeyError, err)
 		cancel()
@@ -68,8 +77,8 @@ func (i *SyslogIngress) Start(ctx context.Context, out chan<- dto.Request, cance
 		select {
 		case <-ctx.Done():
 			i.logger.Info("shutting down syslog server")
-			if err := i.srv.Kill(); err != nil {
-				i.logger.Error("failed to kill syslog server")
+			if err := i.kill(); err != nil {
+				i.logger.Error("failed to kill syslog server", logging.SlogKeyError, err)
 			}
 			return
 		case logPart := <-i.out:
@@ -79,6 +88,9 @@ func (i *SyslogIngress) Start(ctx context.Context, out chan<- dto.Request, cance
 				cancel()
 				return
 			}
+			if !i.filter.allow(logPart) {
+				continue
+			}
 			hostname, ok := logPart[logPartKeyHostname].(string)
 			if !ok {
 				i.logger.Warn("hostname field missing")
@@ -93,12 +105,52 @@ func (i *SyslogIngress) Start(ctx context.Context, out chan<- dto.Request, cance
 				}
 			}
 			i.logger.Debug("line received", "content", line)
-			req, err := i.parser.Parse([]byte(line))
+			// Never the header hostname, which any sender can forge
+			client, _ := logPart[logPartKeyClient].(string)
+			tlsPeer, _ := logPart[logPartKeyTLSPeer].(string)
+			origin := peerOrigin(client, tlsPeer)
+			req, err := i.parse(i.parser, origin, []byte(line))
+			if errors.Is(err, parser.ErrSkipLine) {
+				continue
+			}
 			if err != nil {
 				i.logger.Error("failed to parse line", slogKeyMethod, i.cfg.Method, slogKeyLogFormat, i.cfg.Format, slogKeySource, hostname, slogKeyLine, line)
 				continue
 			}
-			out <- req
+			req.Tag = i.cfg.Tag
+			req.Origin = origin
+			out.Push(ctx, req)
+		}
+	}
+}
+
+func (i *SyslogIngress) Rejections() []dto.IngressRejectionJSON {
+	return i.filter.rejections()
+}
+
+func (i *SyslogIngress) boot(ctx context.Context, cancel context.CancelFunc) error {
+	if i.srv != nil {
+		return i.srv.Boot()
+	}
+
+	stream, err := listenSyslogStream(&i.cfg.Syslog, i.out, i.logger)
+	if err != nil {
+		return err
+	}
+	i.stream = stream
+	go func() {
+		err := stream.Serve(ctx)
+		if err != nil {
+			i.logger.Error("syslog server died", logging.SlogKeyError, err)
+			cancel()
 		}
+	}()
+	return nil
+}
+
+func (i *SyslogIngress) kill() error {
+	if i.srv != nil {
+		return i.srv.Kill()
 	}
+	return i.stream.Close()
 }
@@ -0,0 +1,266 @@
+package ingress
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/HT4w5/nyaago/internal/config"
+	"github.com/HT4w5/nyaago/internal/logging"
+	"gopkg.in/mcuadros/go-syslog.v2"
+	"gopkg.in/mcuadros/go-syslog.v2/format"
+)
+
+const (
+	logPartKeyClient  = "client"
+	logPartKeyTLSPeer = "tls_peer"
+
+	maxFrameLengthDigits = 10
+
+	syslogHandshakeTimeout = 10 * time.Second
+	// A frame must arrive completely within this. Idle connections are closed after it,
+	// senders reconnect when they have new messages
+	syslogFrameTimeout = 5 * time.Minute
+)
+
+var errFrameTooLong = errors.New("syslog frame exceeds max_message_size")
+
+// Syslog over TCP or TLS. Frames use octet counting (RFC 6587 3.4.1, required by RFC 5425)
+// or LF termination, detected per frame. Unlike go-syslog's stream listener,
+// frames are not limited to 64KB and may contain newlines
+type syslogStreamServer struct {
+	ln      net.Listener
+	maxSize int
+	out     syslog.LogPartsChannel
+	logger  *slog.Logger
+
+	mu    sync.Mutex
+	conns map[net.Conn]struct{}
+	wg    sync.WaitGroup
+}
+
+func listenSyslogStream(cfg *config.SyslogConfig, out syslog.LogPartsChannel, logger *slog.Logger) (*syslogStreamServer, error) {
+	var ln net.Listener
+	var err error
+	switch cfg.Transport {
+	case "tcp":
+		ln, err = net.Listen("tcp", cfg.ListenAddr)
+	case "tls":
+		var tlsCfg *tls.Config
+		tlsCfg, err = makeSyslogTLSConfig(&cfg.TLS)
+		if err != nil {
+			return nil, err
+		}
+		ln, err = tls.Listen("tcp", cfg.ListenAddr, tlsCfg)
+	default:
+		return nil, fmt.Errorf("unsupported stream transport: %s", cfg.Transport)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &syslogStreamServer{
+		ln:      ln,
+		maxSize: int(cfg.MaxMessageSize),
+		out:     out,
+		logger:  logger,
+		conns:   make(map[net.Conn]struct{}),
+	}, nil
+}
+
+func makeSyslogTLSConfig(cfg *config.SyslogTLSConfig) (*tls.Config, error) {
+	cert, err := tls.LoadX509KeyPair(cfg.CertFile, cfg.KeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load certificate: %w", err)
+	}
+	tlsCfg := &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   tls.VersionTLS12,
+	}
+
+	// Mutual TLS
+	if cfg.ClientCAFile != "" {
+		caBytes, err := os.ReadFile(cfg.ClientCAFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read client CA: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(caBytes) {
+			return nil, fmt.Errorf("no certificates found in client CA %s", cfg.ClientCAFile)
+		}
+		tlsCfg.ClientCAs = pool
+		tlsCfg.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+	return tlsCfg, nil
+}
+
+// Accept connections until Close. Returns the accept error, or nil after Close
+func (s *syslogStreamServer) Serve(ctx context.Context) error {
+	for {
+		conn, err := s.ln.Accept()
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return nil
+			}
+			return err
+		}
+		s.mu.Lock()
+		s.conns[conn] = struct{}{}
+		s.mu.Unlock()
+		s.wg.Add(1)
+		go s.handleConn(ctx, conn)
+	}
+}
+
+// Stop accepting and close open connections
+func (s *syslogStreamServer) Close() error {
+	err := s.ln.Close()
+	s.mu.Lock()
+	for conn := range s.conns {
+		conn.Close()
+	}
+	s.mu.Unlock()
+	s.wg.Wait()
+	return err
+}
+
+func (s *syslogStreamServer) handleConn(ctx context.Context, conn net.Conn) {
+	defer func() {
+		conn.Close()
+		s.mu.Lock()
+		delete(s.conns, conn)
+		s.mu.Unlock()
+		s.wg.Done()
+	}()
+
+	client := conn.RemoteAddr().String()
+	tlsPeer := ""
+	if tlsConn, ok := conn.(*tls.Conn); ok {
+		handshakeCtx, cancel := context.WithTimeout(ctx, syslogHandshakeTimeout)
+		err := tlsConn.HandshakeContext(handshakeCtx)
+		cancel()
+		if err != nil {
+			s.logger.Warn("tls handshake failed", slogKeySource, client, logging.SlogKeyError, err)
+			return
+		}
+		if certs := tlsConn.ConnectionState().PeerCertificates; len(certs) > 0 {
+			tlsPeer = certs[0].Subject.CommonName
+		}
+	}
+
+	r := bufio.NewReader(conn)
+	for {
+		err := conn.SetReadDeadline(time.Now().Add(syslogFrameTimeout))
+		if err != nil {
+			return
+		}
+		frame, err := readSyslogFrame(r, s.maxSize)
+		if err != nil {
+			if errors.Is(err, os.ErrDeadlineExceeded) {
+				s.logger.Debug("closing idle syslog connection", slogKeySource, client)
+			} else if !errors.Is(err, io.EOF) && !errors.Is(err, net.ErrClosed) {
+				s.logger.Warn("dropping syslog connection", slogKeySource, client, logging.SlogKeyError, err)
+			}
+			return
+		}
+		if len(frame) == 0 {
+			continue
+		}
+
+		parts := parseSyslogFrame(frame, client, tlsPeer)
+		select {
+		case s.out <- parts:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// Read one frame. Octet counted frames start with a digit, others end at LF
+func readSyslogFrame(r *bufio.Reader, maxSize int) ([]byte, error) {
+	b, err := r.Peek(1)
+	if err != nil {
+		return nil, err
+	}
+
+	if b[0] >= '1' && b[0] <= '9' {
+		// Length digits are read one by one so a sender cannot make us buffer an endless prefix
+		n := 0
+		for digits := 0; ; digits++ {
+			c, err := r.ReadByte()
+			if errors.Is(err, io.EOF) {
+				return nil, io.ErrUnexpectedEOF
+			}
+			if err != nil {
+				return nil, err
+			}
+			if c == ' ' {
+				break
+			}
+			if c < '0' || c > '9' || digits == maxFrameLengthDigits {
+				return nil, fmt.Errorf("bad frame length: unexpected %q after %d digits", c, digits)
+			}
+			n = n*10 + int(c-'0')
+		}
+		if n > maxSize {
+			return nil, errFrameTooLong
+		}
+		frame := make([]byte, n)
+		_, err = io.ReadFull(r, frame)
+		if err != nil {
+			return nil, err
+		}
+		return bytes.TrimRight(frame, "\r\n"), nil
+	}
+
+	var frame []byte
+	for {
+		chunk, err := r.ReadSlice('\n')
+		if len(frame)+len(chunk) > maxSize+1 {
+			return nil, errFrameTooLong
+		}
+		frame = append(frame, chunk...)
+		if err == nil {
+			break
+		}
+		if errors.Is(err, bufio.ErrBufferFull) {
+			continue
+		}
+		if errors.Is(err, io.EOF) && len(frame) > 0 {
+			break
+		}
+		return nil, err
+	}
+	return bytes.TrimRight(frame, "\r\n"), nil
+}
+
+// Parse a frame the way go-syslog does for its own listeners
+func parseSyslogFrame(frame []byte, client string, tlsPeer string) format.LogParts {
+	p := syslog.Automatic.GetParser(frame)
+	p.Parse()
+	parts := p.Dump()
+	parts[logPartKeyClient] = client
+	parts[logPartKeyTLSPeer] = tlsPeer
+	if hostname, _ := parts[logPartKeyHostname].(string); hostname == "" {
+		if host, _, err := net.SplitHostPort(client); err == nil {
+			parts[logPartKeyHostname] = host
+		}
+	}
+	return parts
+}
+
+// Check whether a syslog transport is stream based
+func isStreamTransport(transport string) bool {
+	return strings.EqualFold(transport, "tcp") || strings.EqualFold(transport, "tls")
+}
@@ -0,0 +1,126 @@
+package ingress
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestReadSyslogFrame(t *testing.T) {
+	const maxSize = 64
+	tests := []struct {
+		name    string
+		input   string
+		want    []string
+		wantErr error // Error after the wanted frames. nil for io.EOF
+		anyErr  bool  // Any error other than io.EOF
+	}{
+		{
+			name:  "octet counted",
+			input: "11 <13>1 - a b11 <13>1 - c d",
+			want:  []string{"<13>1 - a b", "<13>1 - c d"},
+		},
+		{
+			name:  "octet counted with embedded lf",
+			input: "15 <13>line1\nline2",
+			want:  []string{"<13>line1\nline2"},
+		},
+		{
+			name:  "octet counted with trailing lf",
+			input: "6 <13>a\n6 <13>b\n",
+			want:  []string{"<13>a", "<13>b"},
+		},
+		{
+			name:  "lf terminated",
+			input: "<13>a\n<13>b\r\n<13>c",
+			want:  []string{"<13>a", "<13>b", "<13>c"},
+		},
+		{
+			name:  "mixed framing",
+			input: "<13>a\n5 <13>b",
+			want:  []string{"<13>a", "<13>b"},
+		},
+		{
+			name:  "octet counted at max size",
+			input: "64 " + strings.Repeat("x", 64),
+			want:  []string{strings.Repeat("x", 64)},
+		},
+		{
+			name:    "octet counted oversize",
+			input:   "65 " + strings.Repeat("x", 65),
+			wantErr: errFrameTooLong,
+		},
+		{
+			name:    "lf terminated oversize",
+			input:   "<" + strings.Repeat("x", 80) + "\n",
+			wantErr: errFrameTooLong,
+		},
+		{
+			name:   "too many length digits",
+			input:  strings.Repeat("1", 11) + " x",
+			anyErr: true,
+		},
+		{
+			name:   "endless length digits",
+			input:  strings.Repeat("9", 1<<20),
+			anyErr: true,
+		},
+		{
+			name:   "bad length",
+			input:  "12a <13>",
+			anyErr: true,
+		},
+		{
+			name:    "truncated frame",
+			input:   "20 <13>short",
+			wantErr: io.ErrUnexpectedEOF,
+		},
+		{
+			name:    "truncated length",
+			input:   "5 <13>a12",
+			want:    []string{"<13>a"},
+			wantErr: io.ErrUnexpectedEOF,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := bufio.NewReaderSize(strings.NewReader(tt.input), 16)
+			var got []string
+			var err error
+			for {
+				var frame []byte
+				frame, err = readSyslogFrame(r, maxSize)
+				if err != nil {
+					break
+				}
+				got = append(got, string(frame))
+			}
+
+			if len(got) != len(tt.want) {
+				t.Fatalf("frames = %q, want %q", got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Errorf("frame %d = %q, want %q", i, got[i], tt.want[i])
+				}
+			}
+			switch {
+			case tt.anyErr:
+				if err == nil || errors.Is(err, io.EOF) {
+					t.Errorf("error = %v, want a framing error", err)
+				}
+			case tt.wantErr != nil:
+				if !errors.Is(err, tt.wantErr) {
+					t.Errorf("error = %v, want %v", err, tt.wantErr)
+				}
+			default:
+				if !errors.Is(err, io.EOF) {
+					t.Errorf("error = %v, want io.EOF", err)
+				}
+			}
+		})
+	}
+}
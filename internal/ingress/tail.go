@@ -1,44 +1,66 @@
 package ingress
 
 import (
+	"bufio"
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"log/slog"
+	"os"
+	"strings"
+	"time"
 
 	"github.com/HT4w5/nyaago/internal/config"
 	"github.com/HT4w5/nyaago/internal/logging"
-	"github.com/HT4w5/nyaago/pkg/dto"
+	"github.com/HT4w5/nyaago/internal/pipeline"
 	"github.com/HT4w5/nyaago/pkg/parser"
+	"github.com/dgraph-io/badger/v4"
 	"github.com/nxadm/tail"
 )
 
+const (
+	tailRotatedSuffix = ".1" // logrotate's name for the previous file
+	tailRetryInterval = 100 * time.Millisecond
+)
+
 type TailIngress struct {
-	cfg    *config.IngressConfig
-	parser parser.Parser
-	t      *tail.Tail
-	logger *slog.Logger
+	sourceStats
+	cfg     *config.IngressConfig
+	parser  parser.Parser
+	db      *badger.DB
+	t       *tail.Tail
+	inode   uint64          // Inode of the tailed file at start
+	catchUp *tailCheckpoint // Unread remainder of the rotated file. nil if none
+	pos     tailCheckpoint  // Position after the last line handled
+	logger  *slog.Logger
 }
 
-func makeTailIngress(cfg *config.IngressConfig, p parser.Parser, logger *slog.Logger) (*TailIngress, error) {
+func makeTailIngress(cfg *config.IngressConfig, p parser.Parser, db *badger.DB, logger *slog.Logger) (*TailIngress, error) {
 	i := &TailIngress{
-		parser: p,
-		logger: logger,
-		cfg:    cfg,
+		sourceStats: sourceStats{name: cfg.Name},
+		parser:      p,
+		db:          db,
+		logger:      logger,
+		cfg:         cfg,
+		pos: tailCheckpoint{
+			Source: cfg.Name,
+		},
+	}
+
+	location, err := i.resumeLocation()
+	if err != nil {
+		return nil, err
 	}
 
 	// Setup tail
-	var err error
 	i.t, err = tail.TailFile(cfg.Tail.Path, tail.Config{
 		Follow:    true,
 		ReOpen:    true,
 		MustExist: false,
 		Poll:      cfg.Tail.Poll,
 		Logger:    slog.NewLogLogger(i.logger.Handler(), slog.LevelInfo),
-		Location: &tail.SeekInfo{
-			Offset: 0,
-			Whence: io.SeekEnd,
-		},
+		Location:  location,
 	})
 	if err != nil {
 		return nil, fmt.Errorf("failed to create tail: %w", err)
@@ -47,8 +69,72 @@ func makeTailIngress(cfg *config.IngressConfig, p parser.Parser, logger *slog.Lo
 	return i, nil
 }
 
-func (i *TailIngress) Start(ctx context.Context, out chan<- dto.Request, cancel context.CancelFunc) {
+// Decide where to start from the stored checkpoint.
+// Without a checkpoint or a file to match it against, start at the end of the file
+func (i *TailIngress) resumeLocation() (*tail.SeekInfo, error) {
+	end := &tail.SeekInfo{
+		Offset: 0,
+		Whence: io.SeekEnd,
+	}
+
+	inode, size, err := statInode(i.cfg.Tail.Path)
+	if err != nil {
+		return end, nil
+	}
+	i.inode = inode
+	i.pos.Inode = inode
+	i.pos.Offset = size
+
+	cp, ok, err := loadTailCheckpoint(i.db, i.cfg.Name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load checkpoint: %w", err)
+	}
+	if !ok {
+		return end, nil
+	}
+
+	if cp.Inode == inode && cp.Offset <= size {
+		i.logger.Info("resuming from checkpoint", "offset", cp.Offset)
+		i.pos.Offset = cp.Offset
+		return &tail.SeekInfo{
+			Offset: cp.Offset,
+			Whence: io.SeekStart,
+		}, nil
+	}
+
+	// File was rotated or truncated while stopped. Finish the rotated file, then read the new one from the start
+	rotated := i.cfg.Tail.Path + tailRotatedSuffix
+	rInode, rSize, err := statInode(rotated)
+	if err == nil && rInode == cp.Inode && cp.Offset <= rSize {
+		i.logger.Info("resuming from checkpoint in rotated file", "path", rotated, "offset", cp.Offset)
+		i.catchUp = &cp
+	} else {
+		i.logger.Warn("checkpoint matches neither file nor rotated file, lines written while stopped may be lost")
+	}
+	i.pos.Offset = 0
+	return &tail.SeekInfo{
+		Offset: 0,
+		Whence: io.SeekStart,
+	}, nil
+}
+
+func (i *TailIngress) Start(ctx context.Context, out Sink, cancel context.CancelFunc) {
 	i.logger.Info("starting tail")
+	defer i.checkpoint()
+
+	if i.catchUp != nil {
+		err := i.readRotated(ctx, out)
+		if err != nil {
+			i.logger.Error("failed to read rotated file", logging.SlogKeyError, err)
+		}
+		i.pos = tailCheckpoint{
+			Source: i.cfg.Name,
+			Inode:  i.inode,
+		}
+	}
+
+	ticker := time.NewTicker(time.Duration(i.cfg.Tail.CheckpointInterval))
+	defer ticker.Stop()
 	for {
 		select {
 		case <-ctx.Done():
@@ -56,6 +142,8 @@ func (i *TailIngress) Start(ctx context.Context, out chan<- dto.Request, cancel
 			i.t.Stop()
 			i.t.Cleanup()
 			return
+		case <-ticker.C:
+			i.checkpoint()
 		case line := <-i.t.Lines:
 			if line == nil {
 				// Tail failed, cancel global context
@@ -64,13 +152,91 @@ func (i *TailIngress) Start(ctx context.Context, out chan<- dto.Request, cancel
 				cancel()
 				return
 			}
-			i.logger.Debug("line received", "content", line.Text)
-			req, err := i.parser.Parse([]byte(line.Text))
-			if err != nil {
-				i.logger.Error("failed to parse line", slogKeyMethod, i.cfg.Method, slogKeyLogFormat, i.cfg.Format, slogKeySource, i.cfg.Tail.Path, slogKeyLine, line.Text)
+			if !i.handleLine(ctx, line.Text, i.cfg.Tail.Path, out) {
 				continue
 			}
-			out <- req
+			i.advance(line.SeekInfo.Offset)
 		}
 	}
 }
+
+// Parse and send a line. Returns false if ctx is done before the request was queued.
+// The file is a durable buffer, so lines refused on overflow are retried rather than lost
+// behind the checkpoint
+func (i *TailIngress) handleLine(ctx context.Context, text string, path string, out Sink) bool {
+	i.logger.Debug("line received", "content", text)
+	req, err := i.parse(i.parser, "", []byte(text))
+	if errors.Is(err, parser.ErrSkipLine) {
+		return true
+	}
+	if err != nil {
+		i.logger.Error("failed to parse line", slogKeyMethod, i.cfg.Method, slogKeyLogFormat, i.cfg.Format, slogKeySource, path, slogKeyLine, text)
+		return true
+	}
+	req.Tag = i.cfg.Tag
+	for {
+		switch out.Push(ctx, req) {
+		case pipeline.Queued:
+			return true
+		case pipeline.Canceled:
+			return false
+		}
+		select {
+		case <-time.After(tailRetryInterval):
+		case <-ctx.Done():
+			return false
+		}
+	}
+}
+
+// Record the offset after a line. A smaller offset means the file was reopened after rotation or truncation
+func (i *TailIngress) advance(offset int64) {
+	if offset < i.pos.Offset {
+		inode, _, err := statInode(i.cfg.Tail.Path)
+		if err == nil {
+			i.pos.Inode = inode
+		}
+	}
+	i.pos.Offset = offset
+}
+
+// Read complete lines left in the rotated file after the checkpoint
+func (i *TailIngress) readRotated(ctx context.Context, out Sink) error {
+	path := i.cfg.Tail.Path + tailRotatedSuffix
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	_, err = f.Seek(i.catchUp.Offset, io.SeekStart)
+	if err != nil {
+		return err
+	}
+	i.pos = *i.catchUp
+
+	r := bufio.NewReader(f)
+	for {
+		line, err := r.ReadString('\n')
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if !i.handleLine(ctx, strings.TrimRight(line, "\r\n"), path, out) {
+			return ctx.Err()
+		}
+		i.pos.Offset += int64(len(line))
+	}
+}
+
+func (i *TailIngress) checkpoint() {
+	if i.pos.Inode == 0 {
+		return
+	}
+	err := saveTailCheckpoint(i.db, i.pos)
+	if err != nil {
+		i.logger.Error("failed to save checkpoint", logging.SlogKeyError, err)
+	}
+}
@@ -0,0 +1,257 @@
+//go:build unix
+
+package ingress
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/HT4w5/nyaago/internal/config"
+	"github.com/HT4w5/nyaago/internal/pipeline"
+	"github.com/HT4w5/nyaago/pkg/parser"
+	"github.com/dgraph-io/badger/v4"
+)
+
+const testTailLines = testNginxLine + "\n" + testNginxLine + "\n"
+
+// Tail source over path, without starting the tail itself
+func makeTestTail(t *testing.T, path string) *TailIngress {
+	t.Helper()
+	db, err := badger.Open(badger.DefaultOptions("").WithInMemory(true).WithLogger(nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	p, err := parser.MakeParser("nginxcombined", parser.Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := &config.IngressConfig{Name: "test", Method: "tail", Format: "nginxcombined"}
+	cfg.Tail.Path = path
+	return &TailIngress{
+		sourceStats: sourceStats{name: cfg.Name},
+		cfg:         cfg,
+		parser:      p,
+		db:          db,
+		logger:      slog.New(slog.DiscardHandler),
+		pos:         tailCheckpoint{Source: cfg.Name},
+	}
+}
+
+func writeTestFile(t *testing.T, path, content string) uint64 {
+	t.Helper()
+	err := os.WriteFile(path, []byte(content), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	inode, _, err := statInode(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return inode
+}
+
+func TestTailResumeLocation(t *testing.T) {
+	tests := []struct {
+		name string
+		// Create files under path and return the stored checkpoint, nil for none
+		setup       func(t *testing.T, path string) *tailCheckpoint
+		wantWhence  int
+		wantOffset  int64
+		wantCatchUp bool
+	}{
+		{
+			name:       "no file",
+			setup:      func(t *testing.T, path string) *tailCheckpoint { return nil },
+			wantWhence: io.SeekEnd,
+		},
+		{
+			name: "no checkpoint",
+			setup: func(t *testing.T, path string) *tailCheckpoint {
+				writeTestFile(t, path, testTailLines)
+				return nil
+			},
+			wantWhence: io.SeekEnd,
+		},
+		{
+			name: "same inode",
+			setup: func(t *testing.T, path string) *tailCheckpoint {
+				inode := writeTestFile(t, path, testTailLines)
+				return &tailCheckpoint{Inode: inode, Offset: int64(len(testNginxLine) + 1)}
+			},
+			wantWhence: io.SeekStart,
+			wantOffset: int64(len(testNginxLine) + 1),
+		},
+		{
+			name: "truncated",
+			setup: func(t *testing.T, path string) *tailCheckpoint {
+				inode := writeTestFile(t, path, testTailLines)
+				return &tailCheckpoint{Inode: inode, Offset: int64(len(testTailLines) + 1)}
+			},
+			wantWhence: io.SeekStart,
+		},
+		{
+			name: "rotated",
+			setup: func(t *testing.T, path string) *tailCheckpoint {
+				inode := writeTestFile(t, path+tailRotatedSuffix, testTailLines)
+				writeTestFile(t, path, testTailLines)
+				return &tailCheckpoint{Inode: inode, Offset: int64(len(testNginxLine) + 1)}
+			},
+			wantWhence:  io.SeekStart,
+			wantCatchUp: true,
+		},
+		{
+			name: "rotated and truncated",
+			setup: func(t *testing.T, path string) *tailCheckpoint {
+				inode := writeTestFile(t, path+tailRotatedSuffix, testTailLines)
+				writeTestFile(t, path, testTailLines)
+				return &tailCheckpoint{Inode: inode, Offset: int64(len(testTailLines) + 1)}
+			},
+			wantWhence: io.SeekStart,
+		},
+		{
+			name: "unmatched checkpoint",
+			setup: func(t *testing.T, path string) *tailCheckpoint {
+				writeTestFile(t, path+tailRotatedSuffix, testTailLines)
+				inode := writeTestFile(t, path, testTailLines)
+				// Neither file has this inode
+				return &tailCheckpoint{Inode: inode + 1000, Offset: 1}
+			},
+			wantWhence: io.SeekStart,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "access.log")
+			i := makeTestTail(t, path)
+			if cp := tt.setup(t, path); cp != nil {
+				cp.Source = i.cfg.Name
+				err := saveTailCheckpoint(i.db, *cp)
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			location, err := i.resumeLocation()
+			if err != nil {
+				t.Fatalf("resumeLocation() error = %v", err)
+			}
+			if location.Whence != tt.wantWhence || location.Offset != tt.wantOffset {
+				t.Errorf("location = %+v, want whence %d offset %d", *location, tt.wantWhence, tt.wantOffset)
+			}
+			if (i.catchUp != nil) != tt.wantCatchUp {
+				t.Errorf("catchUp = %+v, want %v", i.catchUp, tt.wantCatchUp)
+			}
+			if location.Whence == io.SeekStart && i.pos.Offset != location.Offset {
+				t.Errorf("pos.Offset = %d, want %d", i.pos.Offset, location.Offset)
+			}
+		})
+	}
+}
+
+func TestTailReadRotated(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "access.log")
+	first := testNginxLine + "\n"
+	// The partial last line is still being written and is left for later
+	content := first + testTailLines + "192.0.2.1 - -"
+	inode := writeTestFile(t, path+tailRotatedSuffix, content)
+
+	i := makeTestTail(t, path)
+	i.catchUp = &tailCheckpoint{Source: i.cfg.Name, Inode: inode, Offset: int64(len(first))}
+	sink := &testSink{}
+	err := i.readRotated(context.Background(), sink)
+	if err != nil {
+		t.Fatalf("readRotated() error = %v", err)
+	}
+	if sink.len() != 2 {
+		t.Errorf("pushed = %d, want 2", sink.len())
+	}
+	wantOffset := int64(len(first) + len(testTailLines))
+	if i.pos.Inode != inode || i.pos.Offset != wantOffset {
+		t.Errorf("pos = %+v, want inode %d offset %d", i.pos, inode, wantOffset)
+	}
+}
+
+func TestTailAdvance(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "access.log")
+	oldInode := writeTestFile(t, path+tailRotatedSuffix, testTailLines)
+	newInode := writeTestFile(t, path, testTailLines)
+
+	i := makeTestTail(t, path)
+	i.pos.Inode = oldInode
+	i.pos.Offset = 100
+
+	i.advance(200)
+	if i.pos.Inode != oldInode || i.pos.Offset != 200 {
+		t.Errorf("pos after advance(200) = %+v, want inode %d offset 200", i.pos, oldInode)
+	}
+	// Reopened after rotation
+	i.advance(int64(len(testNginxLine) + 1))
+	if i.pos.Inode != newInode || i.pos.Offset != int64(len(testNginxLine)+1) {
+		t.Errorf("pos after reopen = %+v, want inode %d", i.pos, newInode)
+	}
+
+	i.checkpoint()
+	cp, ok, err := loadTailCheckpoint(i.db, i.cfg.Name)
+	if err != nil || !ok {
+		t.Fatalf("loadTailCheckpoint() = %v, %v", ok, err)
+	}
+	if cp != i.pos {
+		t.Errorf("checkpoint = %+v, want %+v", cp, i.pos)
+	}
+}
+
+func TestTailHandleLineRetries(t *testing.T) {
+	i := makeTestTail(t, filepath.Join(t.TempDir(), "access.log"))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	sink := &testSink{result: pipeline.Dropped}
+	if i.handleLine(ctx, testNginxLine, i.cfg.Tail.Path, sink) {
+		t.Error("handleLine() = true for a refused line, want false once ctx is done")
+	}
+	if !i.handleLine(ctx, strings.Repeat("x", 10), i.cfg.Tail.Path, sink) {
+		t.Error("handleLine() = false for an unparsable line, want true")
+	}
+}
+
+// Sources checkpoint concurrently, each under its own key
+func TestTailCheckpointSources(t *testing.T) {
+	i := makeTestTail(t, filepath.Join(t.TempDir(), "access.log"))
+	sources := []string{"web1", "web2"}
+
+	var wg sync.WaitGroup
+	errs := make(chan error, len(sources))
+	for k, source := range sources {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for n := range 100 {
+				want := tailCheckpoint{Source: source, Inode: uint64(k + 1), Offset: int64(n)}
+				err := saveTailCheckpoint(i.db, want)
+				if err != nil {
+					errs <- err
+					return
+				}
+				cp, ok, err := loadTailCheckpoint(i.db, source)
+				if err != nil || !ok || cp != want {
+					errs <- fmt.Errorf("checkpoint of %s = %+v, %v, %v, want %+v", source, cp, ok, err, want)
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Error(err)
+	}
+}
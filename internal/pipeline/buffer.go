@@ -0,0 +1,169 @@
+package pipeline
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/HT4w5/nyaago/internal/config"
+	"github.com/HT4w5/nyaago/pkg/dto"
+)
+
+type overflowPolicy int
+
+const (
+	overflowBlock overflowPolicy = iota
+	overflowDropOldest
+	overflowDropNewest
+	overflowSample
+)
+
+// Outcome of a push
+type PushResult int
+
+const (
+	Queued   PushResult = iota // Queued for analysis
+	Dropped                    // Dropped by the overflow policy
+	Canceled                   // ctx was done before the request was queued or dropped
+)
+
+// Bounded ring buffer of requests between ingress sources and analysis.
+// A full buffer blocks producers or drops requests according to its overflow policy
+type Buffer struct {
+	cfg    *config.PipelineConfig
+	policy overflowPolicy
+
+	mu       sync.Mutex
+	ring     []dto.Request
+	head     int // Index of the oldest request
+	count    int
+	dropped  uint64
+	overflow uint64 // Requests arriving while full, for sampling
+
+	notEmpty chan struct{}
+	notFull  chan struct{}
+}
+
+func MakeBuffer(cfg *config.PipelineConfig) (*Buffer, error) {
+	b := &Buffer{
+		cfg:      cfg,
+		ring:     make([]dto.Request, cfg.BufferSize),
+		notEmpty: make(chan struct{}, 1),
+		notFull:  make(chan struct{}, 1),
+	}
+
+	switch cfg.Overflow {
+	case "block":
+		b.policy = overflowBlock
+	case "drop_oldest":
+		b.policy = overflowDropOldest
+	case "drop_newest":
+		b.policy = overflowDropNewest
+	case "sample":
+		b.policy = overflowSample
+	default:
+		return nil, fmt.Errorf("unsupported overflow policy: %s", cfg.Overflow)
+	}
+	return b, nil
+}
+
+// Add a request. Only blocks with overflow block.
+// With drop_oldest the request is always queued, at the cost of the oldest queued one
+func (b *Buffer) Push(ctx context.Context, req dto.Request) PushResult {
+	for {
+		b.mu.Lock()
+		if b.count < len(b.ring) {
+			b.ring[(b.head+b.count)%len(b.ring)] = req
+			b.count++
+			b.wakeLocked()
+			b.mu.Unlock()
+			return Queued
+		}
+
+		switch b.policy {
+		case overflowDropOldest:
+			b.replaceOldestLocked(req)
+			b.mu.Unlock()
+			return Queued
+		case overflowDropNewest:
+			b.dropped++
+			b.mu.Unlock()
+			return Dropped
+		case overflowSample:
+			b.overflow++
+			res := Dropped
+			if b.overflow%uint64(b.cfg.SampleRate) == 0 {
+				b.replaceOldestLocked(req)
+				res = Queued
+			} else {
+				b.dropped++
+			}
+			b.mu.Unlock()
+			return res
+		}
+		b.mu.Unlock()
+
+		select {
+		case <-b.notFull:
+		case <-ctx.Done():
+			return Canceled
+		}
+	}
+}
+
+// Take the oldest request, waiting until one is available.
+// Queued requests are still returned after ctx is done. Returns false once ctx is done and the buffer is empty
+func (b *Buffer) Pop(ctx context.Context) (dto.Request, bool) {
+	for {
+		b.mu.Lock()
+		if b.count > 0 {
+			req := b.ring[b.head]
+			b.ring[b.head] = dto.Request{}
+			b.head = (b.head + 1) % len(b.ring)
+			b.count--
+			b.wakeLocked()
+			b.mu.Unlock()
+			return req, true
+		}
+		b.mu.Unlock()
+
+		select {
+		case <-b.notEmpty:
+		case <-ctx.Done():
+			return dto.Request{}, false
+		}
+	}
+}
+
+func (b *Buffer) Stats() dto.BufferStatsJSON {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return dto.BufferStatsJSON{
+		Capacity: len(b.ring),
+		Queued:   b.count,
+		Overflow: b.cfg.Overflow,
+		Dropped:  b.dropped,
+	}
+}
+
+func (b *Buffer) replaceOldestLocked(req dto.Request) {
+	b.ring[b.head] = req
+	b.head = (b.head + 1) % len(b.ring)
+	b.dropped++
+}
+
+// Signal waiters. Each woken waiter passes the signal on while the condition holds
+func (b *Buffer) wakeLocked() {
+	if b.count > 0 {
+		select {
+		case b.notEmpty <- struct{}{}:
+		default:
+		}
+	}
+	if b.count < len(b.ring) {
+		select {
+		case b.notFull <- struct{}{}:
+		default:
+		}
+	}
+}
@@ -0,0 +1,143 @@
+package pipeline
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/HT4w5/nyaago/internal/config"
+	"github.com/HT4w5/nyaago/pkg/dto"
+)
+
+func TestBufferOverflow(t *testing.T) {
+	tests := []struct {
+		name        string
+		overflow    string
+		push        int
+		wantSent    []int64
+		wantDropped uint64 // Requests lost, including replaced ones
+		wantRefused int    // Pushes reporting Dropped
+	}{
+		{
+			name:     "fits",
+			overflow: "drop_newest",
+			push:     3,
+			wantSent: []int64{0, 1, 2},
+		},
+		{
+			name:        "drop newest",
+			overflow:    "drop_newest",
+			push:        6,
+			wantSent:    []int64{0, 1, 2, 3},
+			wantDropped: 2,
+			wantRefused: 2,
+		},
+		{
+			name:        "drop oldest",
+			overflow:    "drop_oldest",
+			push:        6,
+			wantSent:    []int64{2, 3, 4, 5},
+			wantDropped: 2,
+		},
+		{
+			name:        "sample",
+			overflow:    "sample",
+			push:        8,
+			wantSent:    []int64{2, 3, 5, 7},
+			wantDropped: 4,
+			wantRefused: 2,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b, err := MakeBuffer(&config.PipelineConfig{BufferSize: 4, Overflow: tt.overflow, SampleRate: 2})
+			if err != nil {
+				t.Fatalf("MakeBuffer() error = %v", err)
+			}
+			refused := 0
+			for i := range tt.push {
+				switch b.Push(context.Background(), dto.Request{Sent: int64(i)}) {
+				case Dropped:
+					refused++
+				case Canceled:
+					t.Fatalf("Push() = Canceled")
+				}
+			}
+			if refused != tt.wantRefused {
+				t.Errorf("Push() returned Dropped %d times, want %d", refused, tt.wantRefused)
+			}
+
+			ctx, cancel := context.WithCancel(context.Background())
+			cancel()
+			var got []int64
+			for {
+				req, ok := b.Pop(ctx)
+				if !ok {
+					break
+				}
+				got = append(got, req.Sent)
+			}
+			if len(got) != len(tt.wantSent) {
+				t.Fatalf("popped %v, want %v", got, tt.wantSent)
+			}
+			for i := range got {
+				if got[i] != tt.wantSent[i] {
+					t.Fatalf("popped %v, want %v", got, tt.wantSent)
+				}
+			}
+			if dropped := b.Stats().Dropped; dropped != tt.wantDropped {
+				t.Errorf("Dropped = %d, want %d", dropped, tt.wantDropped)
+			}
+		})
+	}
+}
+
+func TestBufferBlock(t *testing.T) {
+	b, err := MakeBuffer(&config.PipelineConfig{BufferSize: 1, Overflow: "block"})
+	if err != nil {
+		t.Fatalf("MakeBuffer() error = %v", err)
+	}
+	b.Push(context.Background(), dto.Request{Sent: 1})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if res := b.Push(ctx, dto.Request{Sent: 2}); res != Canceled {
+		t.Fatalf("Push() on full buffer = %d, want Canceled after timeout", res)
+	}
+
+	done := make(chan PushResult)
+	go func() {
+		done <- b.Push(context.Background(), dto.Request{Sent: 3})
+	}()
+	if req, _ := b.Pop(context.Background()); req.Sent != 1 {
+		t.Errorf("Pop() = %d, want 1", req.Sent)
+	}
+	if res := <-done; res != Queued {
+		t.Errorf("blocked Push() = %d, want Queued", res)
+	}
+	if req, _ := b.Pop(context.Background()); req.Sent != 3 {
+		t.Errorf("Pop() = %d, want 3", req.Sent)
+	}
+}
+
+func TestBufferDrain(t *testing.T) {
+	b, err := MakeBuffer(&config.PipelineConfig{BufferSize: 4, Overflow: "block"})
+	if err != nil {
+		t.Fatalf("MakeBuffer() error = %v", err)
+	}
+	b.Push(context.Background(), dto.Request{Sent: 1})
+	b.Push(context.Background(), dto.Request{Sent: 2})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	for _, want := range []int64{1, 2} {
+		req, ok := b.Pop(ctx)
+		if !ok || req.Sent != want {
+			t.Fatalf("Pop() after cancel = %d, %v, want %d, true", req.Sent, ok, want)
+		}
+	}
+	if _, ok := b.Pop(ctx); ok {
+		t.Error("Pop() on drained buffer = true, want false")
+	}
+}
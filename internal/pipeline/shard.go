@@ -0,0 +1,74 @@
+package pipeline
+
+import (
+	"context"
+	"hash/fnv"
+	"net/netip"
+
+	"github.com/HT4w5/nyaago/internal/config"
+	"github.com/HT4w5/nyaago/pkg/dto"
+)
+
+// Shard index of a client address in [0, n). Requests of a client always map to the same shard
+func Shard(addr netip.Addr, n int) int {
+	if n <= 1 {
+		return 0
+	}
+	b := addr.As16()
+	h := fnv.New32a()
+	h.Write(b[:])
+	return int(h.Sum32() % uint32(n))
+}
+
+// One buffer per analysis worker. Requests are routed by client address
+type Sharded struct {
+	cfg     *config.PipelineConfig
+	buffers []*Buffer
+}
+
+func MakeSharded(cfg *config.PipelineConfig) (*Sharded, error) {
+	// Capacity is split between shards
+	shardCfg := *cfg
+	shardCfg.BufferSize = (cfg.BufferSize + cfg.Workers - 1) / cfg.Workers
+
+	s := &Sharded{
+		cfg:     cfg,
+		buffers: make([]*Buffer, cfg.Workers),
+	}
+	for i := range s.buffers {
+		b, err := MakeBuffer(&shardCfg)
+		if err != nil {
+			return nil, err
+		}
+		s.buffers[i] = b
+	}
+	return s, nil
+}
+
+func (s *Sharded) Push(ctx context.Context, req dto.Request) PushResult {
+	return s.buffers[Shard(req.Client, len(s.buffers))].Push(ctx, req)
+}
+
+// Buffer of shard i
+func (s *Sharded) Shard(i int) *Buffer {
+	return s.buffers[i]
+}
+
+func (s *Sharded) Len() int {
+	return len(s.buffers)
+}
+
+// Totals over all shards
+func (s *Sharded) Stats() dto.BufferStatsJSON {
+	res := dto.BufferStatsJSON{
+		Workers:  len(s.buffers),
+		Overflow: s.cfg.Overflow,
+	}
+	for _, b := range s.buffers {
+		st := b.Stats()
+		res.Capacity += st.Capacity
+		res.Queued += st.Queued
+		res.Dropped += st.Dropped
+	}
+	return res
+}
@@ -0,0 +1,45 @@
+package pipeline
+
+import (
+	"net/netip"
+	"testing"
+)
+
+func TestShard(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b netip.Addr
+		n    int
+	}{
+		{
+			name: "same address",
+			a:    netip.MustParseAddr("192.0.2.10"),
+			b:    netip.MustParseAddr("192.0.2.10"),
+			n:    8,
+		},
+		{
+			name: "ipv4 mapped",
+			a:    netip.MustParseAddr("192.0.2.10"),
+			b:    netip.MustParseAddr("::ffff:192.0.2.10"),
+			n:    8,
+		},
+		{
+			name: "single shard",
+			a:    netip.MustParseAddr("192.0.2.10"),
+			b:    netip.MustParseAddr("2001:db8::1"),
+			n:    1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sa, sb := Shard(tt.a, tt.n), Shard(tt.b, tt.n)
+			if sa != sb {
+				t.Errorf("Shard(%v) = %d, Shard(%v) = %d, want equal", tt.a, sa, tt.b, sb)
+			}
+			if sa < 0 || sa >= tt.n {
+				t.Errorf("Shard(%v) = %d, out of range [0, %d)", tt.a, sa, tt.n)
+			}
+		})
+	}
+}
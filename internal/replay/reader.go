@@ -0,0 +1,54 @@
+package replay
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+type multiCloser struct {
+	io.Reader
+	closers []io.Closer
+}
+
+func (m *multiCloser) Close() error {
+	var firstErr error
+	for _, c := range m.closers {
+		if err := c.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+// Open a log file, decompressing .gz and .zst by extension
+func openLog(path string) (io.ReadCloser, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+
+	switch filepath.Ext(path) {
+	case ".gz":
+		gz, err := gzip.NewReader(f)
+		if err != nil {
+			f.Close()
+			return nil, fmt.Errorf("failed to open gzip stream: %w", err)
+		}
+		return &multiCloser{Reader: gz, closers: []io.Closer{gz, f}}, nil
+	case ".zst":
+		zr, err := zstd.NewReader(f)
+		if err != nil {
+			f.Close()
+			return nil, fmt.Errorf("failed to open zstd stream: %w", err)
+		}
+		rc := zr.IOReadCloser()
+		return &multiCloser{Reader: rc, closers: []io.Closer{rc, f}}, nil
+	default:
+		return f, nil
+	}
+}
@@ -0,0 +1,271 @@
+package replay
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"slices"
+	"time"
+
+	"github.com/HT4w5/nyaago/internal/allowlist"
+	"github.com/HT4w5/nyaago/internal/analyzer"
+	"github.com/HT4w5/nyaago/internal/clock"
+	"github.com/HT4w5/nyaago/internal/config"
+	"github.com/HT4w5/nyaago/internal/ingress"
+	"github.com/HT4w5/nyaago/internal/logging"
+	"github.com/HT4w5/nyaago/internal/router"
+	"github.com/HT4w5/nyaago/internal/rulelist"
+	"github.com/HT4w5/nyaago/pkg/dto"
+	"github.com/HT4w5/nyaago/pkg/parser"
+	"github.com/dgraph-io/badger/v4"
+)
+
+const (
+	slogModuleName = "replay"
+	slogGroupName  = "replay"
+
+	maxLineSize = 1024 * 1024
+)
+
+type Options struct {
+	Source   string        // Ingress source providing format and parser options. Empty for the first one
+	Interval time.Duration // Log time between rule reports, like an egress interval
+	Output   string        // Rule list path. Empty for stdout
+}
+
+// Feeds historical logs through the analyzers with log timestamps as the clock.
+// State lives in an in-memory DB, so the live DB is never touched.
+// Analyzer record TTLs are checked against log time as well
+type Replay struct {
+	opts      Options
+	source    *config.IngressConfig
+	parser    parser.Parser
+	db        *badger.DB
+	clk       *clock.Manual
+	allowlist *allowlist.AllowList
+	rulelist  *rulelist.RuleList
+	am        *analyzer.AnalyzerManager
+	router    *router.Router
+	timers    []timer
+	stats     stats
+	logger    *slog.Logger
+}
+
+// Periodic work driven by log time
+type timer struct {
+	interval time.Duration
+	next     time.Time
+	fn       func()
+}
+
+type stats struct {
+	Lines   int
+	Parsed  int
+	Failed  int
+	Skipped int
+	First   time.Time
+	Last    time.Time
+}
+
+func MakeReplay(cfg *config.Config, opts Options) (*Replay, error) {
+	if opts.Interval <= 0 {
+		return nil, fmt.Errorf("report interval must be positive")
+	}
+
+	r := &Replay{
+		opts:   opts,
+		clk:    &clock.Manual{},
+		logger: logging.GetLogger().With(logging.SlogKeyModule, slogModuleName).WithGroup(slogGroupName),
+	}
+
+	// Select source
+	if opts.Source == "" {
+		r.source = &cfg.Ingress[0]
+	} else {
+		for i := range cfg.Ingress {
+			if cfg.Ingress[i].Name == opts.Source {
+				r.source = &cfg.Ingress[i]
+			}
+		}
+		if r.source == nil {
+			return nil, fmt.Errorf("ingress source not found: %s", opts.Source)
+		}
+	}
+
+	var err error
+	r.parser, err = ingress.MakeParser(r.source)
+	if err != nil {
+		return nil, err
+	}
+
+	// Open in-memory DB
+	r.db, err = badger.Open(badger.DefaultOptions("").WithInMemory(true).WithLogger(nil))
+	if err != nil {
+		return nil, fmt.Errorf("failed to open db: %w", err)
+	}
+
+	r.allowlist, err = allowlist.MakeAllowList(&cfg.AllowList, r.db)
+	if err != nil {
+		r.db.Close()
+		return nil, fmt.Errorf("failed to create allowlist: %w", err)
+	}
+	r.rulelist, err = rulelist.MakeRuleList(cfg, r.db, r.allowlist, r.clk)
+	if err != nil {
+		r.db.Close()
+		return nil, fmt.Errorf("failed to create rulelist: %w", err)
+	}
+	r.am = analyzer.MakeAnalyzerManager(&cfg.Analyzers, r.db, r.clk, 1)
+	r.router, err = router.MakeRouter(&cfg.Router, r.am)
+	if err != nil {
+		r.db.Close()
+		return nil, fmt.Errorf("failed to create router: %w", err)
+	}
+
+	// Analyzer tickers and rule reports run on log time instead of Start's wall clock tickers
+	for _, v := range r.am.Analyzers() {
+		if p, ok := v.(analyzer.Periodic); ok {
+			r.timers = append(r.timers, timer{interval: p.Interval(), fn: p.Tick})
+		}
+	}
+	r.timers = append(r.timers, timer{
+		interval: opts.Interval,
+		fn:       func() { r.am.SaveRules(r.rulelist) },
+	})
+
+	return r, nil
+}
+
+// Replay files in order, oldest first, and write the resulting rule list
+func (r *Replay) Run(ctx context.Context, paths []string) error {
+	for _, path := range paths {
+		err := r.replayFile(ctx, path)
+		if err != nil {
+			return fmt.Errorf("failed to replay %s: %w", path, err)
+		}
+	}
+
+	// Flush pending analyzer state
+	for _, t := range r.timers {
+		t.fn()
+	}
+
+	r.logger.Info("replay finished",
+		"lines", r.stats.Lines,
+		"parsed", r.stats.Parsed,
+		"failed", r.stats.Failed,
+		"skipped", r.stats.Skipped,
+		"first", r.stats.First,
+		"last", r.stats.Last,
+	)
+
+	return r.writeRules()
+}
+
+func (r *Replay) Close() {
+	r.db.Close()
+}
+
+func (r *Replay) replayFile(ctx context.Context, path string) error {
+	r.logger.Info("replaying file", "path", path)
+	rc, err := openLog(path)
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+
+	sc := bufio.NewScanner(rc)
+	sc.Buffer(make([]byte, 0, 64*1024), maxLineSize)
+	for sc.Scan() {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		r.stats.Lines++
+
+		req, err := r.parser.Parse(sc.Bytes())
+		if errors.Is(err, parser.ErrSkipLine) {
+			r.stats.Skipped++
+			continue
+		}
+		if err != nil {
+			r.stats.Failed++
+			r.logger.Debug("failed to parse line", "path", path, "line", sc.Text(), logging.SlogKeyError, err)
+			continue
+		}
+		req.Tag = r.source.Tag
+		r.stats.Parsed++
+		r.process(req)
+	}
+	return sc.Err()
+}
+
+func (r *Replay) process(req dto.Request) {
+	if r.stats.First.IsZero() || req.Time.Before(r.stats.First) {
+		r.stats.First = req.Time
+	}
+	if req.Time.After(r.stats.Last) {
+		r.stats.Last = req.Time
+	}
+
+	r.clk.Set(req.Time)
+	now := r.clk.Now()
+	for i := range r.timers {
+		t := &r.timers[i]
+		if t.next.IsZero() {
+			t.next = now.Add(t.interval)
+			continue
+		}
+		if now.Before(t.next) {
+			continue
+		}
+		t.fn()
+		// Skip idle intervals on gaps in the log
+		t.next = t.next.Add(t.interval)
+		if !now.Before(t.next) {
+			t.next = now.Add(t.interval)
+		}
+	}
+
+	r.router.ProcessRequest(req)
+}
+
+// Write enforced and shadow rules as a JSON array
+func (r *Replay) writeRules() error {
+	rules, err := r.rulelist.ListRules()
+	if err != nil {
+		return fmt.Errorf("failed to list rules: %w", err)
+	}
+	shadowRules, err := r.rulelist.ListShadowRules()
+	if err != nil {
+		return fmt.Errorf("failed to list shadow rules: %w", err)
+	}
+	rules = append(rules, shadowRules...)
+	slices.SortFunc(rules, func(a, b dto.Rule) int {
+		if c := a.Prefix.Addr().Compare(b.Prefix.Addr()); c != 0 {
+			return c
+		}
+		return a.Prefix.Bits() - b.Prefix.Bits()
+	})
+
+	var w io.Writer = os.Stdout
+	if r.opts.Output != "" {
+		f, err := os.Create(r.opts.Output)
+		if err != nil {
+			return fmt.Errorf("failed to create output: %w", err)
+		}
+		defer f.Close()
+		w = f
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	err = enc.Encode(rules)
+	if err != nil {
+		return fmt.Errorf("failed to write rules: %w", err)
+	}
+	return nil
+}
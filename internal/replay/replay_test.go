@@ -0,0 +1,158 @@
+package replay
+
+import (
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/HT4w5/nyaago/internal/config"
+	"github.com/HT4w5/nyaago/internal/logging"
+	"github.com/HT4w5/nyaago/pkg/dto"
+)
+
+var logStart = time.Date(2025, time.December, 16, 0, 0, 0, 0, time.UTC)
+
+// Leaky bucket that never leaks, so only bucket_ttl empties a bucket
+func makeTestConfig() *config.Config {
+	cfg := &config.Config{}
+	cfg.Ingress = []config.IngressConfig{{Name: "test", Format: "nginxcombined"}}
+	cfg.Router.Flow.Action = "forward"
+	lb := &cfg.Analyzers.LeakyBucket
+	lb.Enabled = true
+	lb.Capacity = 10000
+	lb.BucketTTL = config.Duration(time.Hour)
+	lb.Export.PrefixLength.IPv4 = 24
+	lb.Export.PrefixLength.IPv6 = 64
+	lb.Export.TTL = config.Duration(24 * time.Hour)
+	lb.Export.MinRate = 1000
+	return cfg
+}
+
+func logLine(offset time.Duration, client string, sent int) string {
+	ts := logStart.Add(offset).Format("02/Jan/2006:15:04:05 -0700")
+	return fmt.Sprintf("%s - - [%s] \"GET /file HTTP/1.1\" 200 %d \"-\" \"test\"\n", client, ts, sent)
+}
+
+func writeLog(t *testing.T, path string, lines ...string) {
+	t.Helper()
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	var w io.Writer = f
+	if strings.HasSuffix(path, ".gz") {
+		gz := gzip.NewWriter(f)
+		defer gz.Close()
+		w = gz
+	}
+	for _, v := range lines {
+		_, err := w.Write([]byte(v))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestReplay(t *testing.T) {
+	err := logging.Init(&config.LogConfig{Access: "none"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	older := filepath.Join(dir, "access.log.1.gz")
+	newer := filepath.Join(dir, "access.log")
+	output := filepath.Join(dir, "rules.json")
+
+	writeLog(t, older,
+		logLine(0, "192.0.2.1", 6000),
+		logLine(5*time.Minute, "192.0.2.1", 6000), // Overflows
+		logLine(10*time.Minute, "198.51.100.1", 6000),
+		"not a log line\n",
+	)
+	writeLog(t, newer,
+		// Past bucket_ttl of the previous request, so the bucket starts empty
+		logLine(2*time.Hour, "198.51.100.1", 6000),
+	)
+
+	r, err := MakeReplay(makeTestConfig(), Options{Interval: 10 * time.Minute, Output: output})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	err = r.Run(context.Background(), []string{older, newer})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if r.stats.Lines != 5 || r.stats.Parsed != 4 || r.stats.Failed != 1 {
+		t.Errorf("stats = %+v, want 5 lines, 4 parsed, 1 failed", r.stats)
+	}
+
+	data, err := os.ReadFile(output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var rules []dto.RuleJSON
+	err = json.Unmarshal(data, &rules)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rules) != 1 {
+		t.Fatalf("rules = %s, want one rule", data)
+	}
+	got := rules[0]
+	// Reported by the timer firing at the request 10 minutes in
+	wantExpiry := logStart.Add(10*time.Minute + 24*time.Hour).Format(time.RFC3339)
+	if got.Prefix != "192.0.2.0/24" || got.Source != "leaky_bucket" || got.RateLimit != "1kB" || got.ExpiresAt != wantExpiry {
+		t.Errorf("rule = %+v, want 192.0.2.0/24 limited to 1kB expiring at %s", got, wantExpiry)
+	}
+}
+
+func TestReplayTimers(t *testing.T) {
+	err := logging.Init(&config.LogConfig{Access: "none"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, err := MakeReplay(makeTestConfig(), Options{Interval: time.Minute})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+
+	var fired []time.Time
+	r.timers = []timer{{
+		interval: time.Minute,
+		fn:       func() { fired = append(fired, r.clk.Now()) },
+	}}
+
+	offsets := []time.Duration{
+		0,                // Schedules the first run at 1m
+		30 * time.Second, // Not due
+		time.Minute,      // Due
+		90 * time.Second, // Not due
+		time.Hour,        // Fires once for the gap, next run at 1h1m
+		time.Hour + 30*time.Second,
+		time.Hour + time.Minute,
+	}
+	for _, v := range offsets {
+		r.process(dto.Request{Time: logStart.Add(v)})
+	}
+
+	want := []time.Duration{time.Minute, time.Hour, time.Hour + time.Minute}
+	if len(fired) != len(want) {
+		t.Fatalf("timer fired at %v, want %v", fired, want)
+	}
+	for i, v := range want {
+		if !fired[i].Equal(logStart.Add(v)) {
+			t.Errorf("run %d at %s, want %s", i, fired[i], logStart.Add(v))
+		}
+	}
+}
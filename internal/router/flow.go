@@ -0,0 +1,195 @@
+package router
+
+import (
+	"fmt"
+	"log/slog"
+
+	"github.com/HT4w5/nyaago/internal/analyzer"
+	"github.com/HT4w5/nyaago/internal/config"
+	"github.com/HT4w5/nyaago/internal/logging"
+	"github.com/HT4w5/nyaago/pkg/dto"
+)
+
+const (
+	actionSequence = "sequence"
+	actionMatch    = "match"
+	actionBranch   = "branch"
+	actionDrop     = "drop"
+	actionForward  = "forward"
+)
+
+// A compiled node of the flow tree.
+// run returns false if the request was dropped and processing must stop
+type flow interface {
+	run(request *dto.Request) bool
+}
+
+// Run subflows in order
+type sequenceFlow struct {
+	subflow []flow
+}
+
+func (f *sequenceFlow) run(request *dto.Request) bool {
+	for _, v := range f.subflow {
+		if !v.run(request) {
+			return false
+		}
+	}
+	return true
+}
+
+// Run subflows in order if the request matches
+type matchFlow struct {
+	m       *matcher
+	subflow sequenceFlow
+}
+
+func (f *matchFlow) run(request *dto.Request) bool {
+	if !f.m.match(request) {
+		return true
+	}
+	return f.subflow.run(request)
+}
+
+// Run the first subflow whose matcher matches the request
+type branchFlow struct {
+	cases []branchCase
+}
+
+type branchCase struct {
+	m *matcher // nil matches all
+	f flow
+}
+
+func (f *branchFlow) run(request *dto.Request) bool {
+	for _, v := range f.cases {
+		if v.m.match(request) {
+			return v.f.run(request)
+		}
+	}
+	return true
+}
+
+// Stop processing the request
+type dropFlow struct{}
+
+func (f *dropFlow) run(request *dto.Request) bool {
+	return false
+}
+
+// Send the request to analyzers
+type forwardFlow struct {
+	analyzers []analyzer.Analyzer
+	logger    *slog.Logger
+}
+
+func (f *forwardFlow) run(request *dto.Request) bool {
+	for _, v := range f.analyzers {
+		err := v.Process(*request)
+		if err != nil {
+			f.logger.Error("failed to process request", "analyzer", v.Name(), logging.SlogKeyError, err)
+		}
+	}
+	return true
+}
+
+// Enabled analyzers targeted by forward actions. Implemented by *analyzer.AnalyzerManager
+type analyzerSet interface {
+	Analyzers() []analyzer.Analyzer
+	Lookup(name string) (analyzer.Analyzer, bool)
+}
+
+type compiler struct {
+	am     analyzerSet
+	logger *slog.Logger
+}
+
+// Compile a flow config into a flow tree. path is used for error messages
+func (c *compiler) compile(cfg *config.FlowConfig, path string) (flow, error) {
+	switch cfg.Action {
+	case actionSequence:
+		subflow, err := c.compileSubflow(cfg, path)
+		if err != nil {
+			return nil, err
+		}
+		return &sequenceFlow{
+			subflow: subflow,
+		}, nil
+	case actionMatch:
+		if cfg.Match == nil {
+			return nil, fmt.Errorf("%s: match action requires a matcher", path)
+		}
+		subflow, err := c.compileSubflow(cfg, path)
+		if err != nil {
+			return nil, err
+		}
+		return &matchFlow{
+			m: compileMatcher(cfg.Match),
+			subflow: sequenceFlow{
+				subflow: subflow,
+			},
+		}, nil
+	case actionBranch:
+		f := &branchFlow{
+			cases: make([]branchCase, 0, len(cfg.Subflow)),
+		}
+		for i := range cfg.Subflow {
+			sub := &cfg.Subflow[i]
+			subPath := fmt.Sprintf("%s.subflow[%d]", path, i)
+			var m *matcher
+			if sub.Match != nil {
+				m = compileMatcher(sub.Match)
+			}
+			// A match subflow of a branch acts as a case; its matcher is evaluated by the branch
+			var sf flow
+			var err error
+			if sub.Action == actionMatch {
+				var subflow []flow
+				subflow, err = c.compileSubflow(sub, subPath)
+				sf = &sequenceFlow{
+					subflow: subflow,
+				}
+			} else {
+				sf, err = c.compile(sub, subPath)
+			}
+			if err != nil {
+				return nil, err
+			}
+			f.cases = append(f.cases, branchCase{
+				m: m,
+				f: sf,
+			})
+		}
+		return f, nil
+	case actionDrop:
+		return &dropFlow{}, nil
+	case actionForward:
+		f := &forwardFlow{
+			logger: c.logger,
+		}
+		if cfg.Analyzer == "" {
+			f.analyzers = c.am.Analyzers()
+		} else {
+			a, ok := c.am.Lookup(cfg.Analyzer)
+			if !ok {
+				return nil, fmt.Errorf("%s: analyzer %s not enabled", path, cfg.Analyzer)
+			}
+			f.analyzers = []analyzer.Analyzer{a}
+		}
+		return f, nil
+	default:
+		return nil, fmt.Errorf("%s: unsupported flow action: %s", path, cfg.Action)
+	}
+}
+
+func (c *compiler) compileSubflow(cfg *config.FlowConfig, path string) ([]flow, error) {
+	subflow := make([]flow, 0, len(cfg.Subflow))
+	for i := range cfg.Subflow {
+		f, err := c.compile(&cfg.Subflow[i], fmt.Sprintf("%s.subflow[%d]", path, i))
+		if err != nil {
+			return nil, err
+		}
+		subflow = append(subflow, f)
+	}
+	return subflow, nil
+}
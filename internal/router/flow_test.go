@@ -0,0 +1,179 @@
+package router
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/netip"
+	"slices"
+	"testing"
+
+	"github.com/HT4w5/nyaago/internal/analyzer"
+	"github.com/HT4w5/nyaago/internal/config"
+	"github.com/HT4w5/nyaago/internal/rulelist"
+	"github.com/HT4w5/nyaago/pkg/dto"
+)
+
+// Analyzer recording the requests it processes as "name url"
+type testAnalyzer struct {
+	name string
+	log  *[]string
+}
+
+func (a *testAnalyzer) Name() string                    { return a.name }
+func (a *testAnalyzer) Start(ctx context.Context) error { return nil }
+func (a *testAnalyzer) Report(tx *rulelist.Tx) error    { return nil }
+
+func (a *testAnalyzer) Process(request dto.Request) error {
+	*a.log = append(*a.log, a.name+" "+request.URL)
+	return nil
+}
+
+type testAnalyzerSet []analyzer.Analyzer
+
+func (s testAnalyzerSet) Analyzers() []analyzer.Analyzer {
+	return s
+}
+
+func (s testAnalyzerSet) Lookup(name string) (analyzer.Analyzer, bool) {
+	for _, v := range s {
+		if v.Name() == name {
+			return v, true
+		}
+	}
+	return nil, false
+}
+
+func compileTestFlow(t *testing.T, flowJSON string, am analyzerSet) (flow, error) {
+	t.Helper()
+	var cfg config.FlowConfig
+	err := json.Unmarshal([]byte(flowJSON), &cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := compiler{am: am, logger: slog.New(slog.DiscardHandler)}
+	return c.compile(&cfg, "flow")
+}
+
+func TestFlowDispatch(t *testing.T) {
+	requests := []dto.Request{
+		{Client: netip.MustParseAddr("192.0.2.1"), URL: "/dl/a.iso"},
+		{Client: netip.MustParseAddr("198.51.100.1"), URL: "/dl/b.iso"},
+		{Client: netip.MustParseAddr("198.51.100.1"), URL: "/index.html"},
+	}
+
+	tests := []struct {
+		name string
+		flow string
+		want []string
+	}{
+		{
+			name: "forward to all",
+			flow: `{"action": "forward"}`,
+			want: []string{"a /dl/a.iso", "b /dl/a.iso", "a /dl/b.iso", "b /dl/b.iso", "a /index.html", "b /index.html"},
+		},
+		{
+			name: "forward to one",
+			flow: `{"action": "forward", "analyzer": "b"}`,
+			want: []string{"b /dl/a.iso", "b /dl/b.iso", "b /index.html"},
+		},
+		{
+			name: "drop stops a sequence",
+			flow: `{"action": "sequence", "subflow": [
+				{"action": "forward", "analyzer": "a"},
+				{"action": "drop"},
+				{"action": "forward", "analyzer": "b"}
+			]}`,
+			want: []string{"a /dl/a.iso", "a /dl/b.iso", "a /index.html"},
+		},
+		{
+			name: "match",
+			flow: `{"action": "match", "match": {"url": "^/dl/"}, "subflow": [
+				{"action": "forward", "analyzer": "a"}
+			]}`,
+			want: []string{"a /dl/a.iso", "a /dl/b.iso"},
+		},
+		{
+			name: "drop in match",
+			flow: `{"action": "sequence", "subflow": [
+				{"action": "match", "match": {"client": "192.0.2.0/24"}, "subflow": [{"action": "drop"}]},
+				{"action": "forward", "analyzer": "a"}
+			]}`,
+			want: []string{"a /dl/b.iso", "a /index.html"},
+		},
+		{
+			name: "branch takes the first matching case",
+			flow: `{"action": "branch", "subflow": [
+				{"action": "match", "match": {"client": "192.0.2.0/24"}, "subflow": [{"action": "forward", "analyzer": "a"}]},
+				{"action": "forward", "analyzer": "b", "match": {"url": "^/dl/"}},
+				{"action": "drop"}
+			]}`,
+			want: []string{"a /dl/a.iso", "b /dl/b.iso"},
+		},
+		{
+			name: "drop in branch",
+			flow: `{"action": "sequence", "subflow": [
+				{"action": "branch", "subflow": [
+					{"action": "drop", "match": {"url": "^/dl/"}},
+					{"action": "forward", "analyzer": "a"}
+				]},
+				{"action": "forward", "analyzer": "b"}
+			]}`,
+			want: []string{"a /index.html", "b /index.html"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			am := testAnalyzerSet{
+				&testAnalyzer{name: "a", log: &got},
+				&testAnalyzer{name: "b", log: &got},
+			}
+			f, err := compileTestFlow(t, tt.flow, am)
+			if err != nil {
+				t.Fatalf("compile() error = %v", err)
+			}
+			for _, v := range requests {
+				f.run(&v)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("processed %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFlowCompileErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		flow string
+	}{
+		{
+			name: "unsupported action",
+			flow: `{"action": "reject"}`,
+		},
+		{
+			name: "match without matcher",
+			flow: `{"action": "match", "subflow": [{"action": "drop"}]}`,
+		},
+		{
+			name: "analyzer not enabled",
+			flow: `{"action": "forward", "analyzer": "c"}`,
+		},
+		{
+			name: "error in subflow",
+			flow: `{"action": "branch", "subflow": [{"action": "sequence", "subflow": [{"action": "reject"}]}]}`,
+		},
+	}
+
+	am := testAnalyzerSet{&testAnalyzer{name: "a", log: new([]string)}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := compileTestFlow(t, tt.flow, am)
+			if err == nil {
+				t.Error("compile() error = nil, want error")
+			}
+		})
+	}
+}
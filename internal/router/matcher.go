@@ -0,0 +1,112 @@
+package router
+
+import (
+	"net/netip"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/HT4w5/nyaago/internal/config"
+	"github.com/HT4w5/nyaago/pkg/dto"
+)
+
+// A compiled request matcher. All conditions set must be satisfied
+type matcher struct {
+	client      *netip.Prefix
+	server      *netip.Prefix
+	method      *string
+	url         *regexp.Regexp
+	status      *int
+	sentMin     *int64
+	sentMax     *int64
+	durationMin *time.Duration
+	durationMax *time.Duration
+	host        *regexp.Regexp
+	agent       *regexp.Regexp
+	tag         *string
+	origin      *regexp.Regexp
+}
+
+func compileMatcher(cfg *config.MatcherConfig) *matcher {
+	m := &matcher{
+		method:  cfg.Method,
+		status:  cfg.Status,
+		sentMin: cfg.SentMin,
+		sentMax: cfg.SentMax,
+		tag:     cfg.Tag,
+	}
+	if cfg.Client != nil {
+		m.client = &cfg.Client.Prefix
+	}
+	if cfg.Server != nil {
+		m.server = &cfg.Server.Prefix
+	}
+	if cfg.URL != nil {
+		m.url = cfg.URL.Regexp
+	}
+	if cfg.DurationMin != nil {
+		d := time.Duration(*cfg.DurationMin)
+		m.durationMin = &d
+	}
+	if cfg.DurationMax != nil {
+		d := time.Duration(*cfg.DurationMax)
+		m.durationMax = &d
+	}
+	if cfg.Host != nil {
+		m.host = cfg.Host.Regexp
+	}
+	if cfg.Agent != nil {
+		m.agent = cfg.Agent.Regexp
+	}
+	if cfg.Origin != nil {
+		m.origin = cfg.Origin.Regexp
+	}
+	return m
+}
+
+// A nil matcher matches all requests
+func (m *matcher) match(r *dto.Request) bool {
+	if m == nil {
+		return true
+	}
+	if m.client != nil && !m.client.Contains(r.Client.Unmap()) {
+		return false
+	}
+	if m.server != nil && !m.server.Contains(r.Server.Unmap()) {
+		return false
+	}
+	if m.method != nil && !strings.EqualFold(*m.method, r.Method) {
+		return false
+	}
+	if m.status != nil && *m.status != r.Status {
+		return false
+	}
+	if m.sentMin != nil && r.Sent < *m.sentMin {
+		return false
+	}
+	if m.sentMax != nil && r.Sent > *m.sentMax {
+		return false
+	}
+	if m.durationMin != nil && r.Duration < *m.durationMin {
+		return false
+	}
+	if m.durationMax != nil && r.Duration > *m.durationMax {
+		return false
+	}
+	if m.url != nil && !m.url.MatchString(r.URL) {
+		return false
+	}
+	if m.host != nil && !m.host.MatchString(r.Host) {
+		return false
+	}
+	if m.agent != nil && !m.agent.MatchString(r.Agent) {
+		return false
+	}
+	if m.tag != nil && *m.tag != r.Tag {
+		return false
+	}
+	if m.origin != nil && !m.origin.MatchString(r.Origin) {
+		return false
+	}
+	return true
+}
@@ -0,0 +1,109 @@
+package router
+
+import (
+	"net/netip"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/HT4w5/nyaago/internal/config"
+	"github.com/HT4w5/nyaago/pkg/dto"
+)
+
+func TestMatcherMatch(t *testing.T) {
+	method := "GET"
+	status := 200
+	sentMin := int64(1024)
+	durationMax := config.Duration(time.Second)
+
+	request := dto.Request{
+		Client:   netip.MustParseAddr("192.0.2.10"),
+		Method:   "GET",
+		URL:      "/downloads/debian.iso",
+		Status:   200,
+		Sent:     4096,
+		Duration: 500 * time.Millisecond,
+		Tag:      "edge",
+		Origin:   "web01.example.net",
+	}
+
+	tests := []struct {
+		name string
+		cfg  config.MatcherConfig
+		want bool
+	}{
+		{
+			name: "empty matcher",
+			cfg:  config.MatcherConfig{},
+			want: true,
+		},
+		{
+			name: "client prefix match",
+			cfg: config.MatcherConfig{
+				Client: &config.IPPrefix{Prefix: netip.MustParsePrefix("192.0.2.0/24")},
+			},
+			want: true,
+		},
+		{
+			name: "client prefix mismatch",
+			cfg: config.MatcherConfig{
+				Client: &config.IPPrefix{Prefix: netip.MustParsePrefix("198.51.100.0/24")},
+			},
+			want: false,
+		},
+		{
+			name: "url and method match",
+			cfg: config.MatcherConfig{
+				Method: &method,
+				URL:    &config.Regexp{Regexp: regexp.MustCompile(`^/downloads/`)},
+			},
+			want: true,
+		},
+		{
+			name: "url mismatch",
+			cfg: config.MatcherConfig{
+				URL: &config.Regexp{Regexp: regexp.MustCompile(`^/api/`)},
+			},
+			want: false,
+		},
+		{
+			name: "status, sent and duration in range",
+			cfg: config.MatcherConfig{
+				Status:      &status,
+				SentMin:     &sentMin,
+				DurationMax: &durationMax,
+			},
+			want: true,
+		},
+		{
+			name: "sent below minimum",
+			cfg: config.MatcherConfig{
+				SentMin: func() *int64 { v := int64(8192); return &v }(),
+			},
+			want: false,
+		},
+		{
+			name: "tag mismatch",
+			cfg: config.MatcherConfig{
+				Tag: func() *string { v := "legacy"; return &v }(),
+			},
+			want: false,
+		},
+		{
+			name: "origin match",
+			cfg: config.MatcherConfig{
+				Origin: &config.Regexp{Regexp: regexp.MustCompile(`^web\d+\.`)},
+			},
+			want: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := compileMatcher(&tt.cfg)
+			if got := m.match(&request); got != tt.want {
+				t.Errorf("match() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
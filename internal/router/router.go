@@ -0,0 +1,48 @@
+package router
+
+import (
+	"fmt"
+	"log/slog"
+
+	"github.com/HT4w5/nyaago/internal/analyzer"
+	"github.com/HT4w5/nyaago/internal/config"
+	"github.com/HT4w5/nyaago/internal/logging"
+	"github.com/HT4w5/nyaago/pkg/dto"
+)
+
+const (
+	slogModuleName = "router"
+	slogGroupName  = "router"
+)
+
+// Dispatches requests to analyzers according to a compiled flow tree
+type Router struct {
+	cfg    *config.RouterConfig
+	root   flow
+	logger *slog.Logger
+}
+
+func MakeRouter(cfg *config.RouterConfig, am *analyzer.AnalyzerManager) (*Router, error) {
+	r := &Router{
+		cfg:    cfg,
+		logger: logging.GetLogger().With(logging.SlogKeyModule, slogModuleName).WithGroup(slogGroupName),
+	}
+
+	c := compiler{
+		am:     am,
+		logger: r.logger,
+	}
+	var err error
+	r.root, err = c.compile(&cfg.Flow, "flow")
+	if err != nil {
+		return nil, fmt.Errorf("failed to compile flow: %w", err)
+	}
+
+	return r, nil
+}
+
+// Run a request through the flow tree
+func (r *Router) ProcessRequest(request dto.Request) {
+	r.logger.Debug("routing request", "request", request)
+	r.root.run(&request)
+}
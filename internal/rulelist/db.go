@@ -1,20 +1,31 @@
 package rulelist
 
 import (
+	"errors"
 	"net/netip"
 
+	"github.com/HT4w5/nyaago/internal/clock"
 	"github.com/HT4w5/nyaago/pkg/dto"
 	"github.com/dgraph-io/badger/v4"
 )
 
+var (
+	ErrRuleNotFound    = errors.New("rule not found")
+	ErrRuleAllowListed = errors.New("rule prefix is covered by allowlist")
+)
+
 func (l *RuleList) PutRule(rule dto.Rule) error {
+	if l.al.Check(rule) {
+		return ErrRuleAllowListed
+	}
+
 	entryBytes, err := rule.Marshal()
 	if err != nil {
 		return err
 	}
 	return l.db.Update(func(txn *badger.Txn) error {
 		entry := badger.NewEntry(l.kb.WithObject(rule).Build(), entryBytes)
-		entry.ExpiresAt = uint64(rule.ExpiresAt.Unix())
+		entry.ExpiresAt = uint64(clock.ToWall(l.clk, rule.ExpiresAt).Unix())
 		return txn.SetEntry(entry)
 	})
 }
@@ -34,6 +45,9 @@ func (l *RuleList) GetRule(prefix netip.Prefix) (dto.Rule, error) {
 		})
 	})
 	if err != nil {
+		if err == badger.ErrKeyNotFound {
+			return dto.Rule{}, ErrRuleNotFound
+		}
 		return dto.Rule{}, err
 	}
 	return rule, nil
@@ -1,6 +1,8 @@
 package rulelist
 
 import (
+	"github.com/HT4w5/nyaago/internal/allowlist"
+	"github.com/HT4w5/nyaago/internal/clock"
 	"github.com/HT4w5/nyaago/internal/config"
 	"github.com/HT4w5/nyaago/internal/dbkey"
 	"github.com/HT4w5/nyaago/pkg/dto"
@@ -11,24 +13,41 @@ type RuleList struct {
 	cfg *config.Config
 	db  *badger.DB
 	kb  dbkey.KeyBuilder
+	skb dbkey.KeyBuilder // for shadow rules
+	al  *allowlist.AllowList
+	clk clock.Clock
 }
 
-func MakeRuleList(cfg *config.Config, db *badger.DB) (*RuleList, error) {
+func MakeRuleList(cfg *config.Config, db *badger.DB, al *allowlist.AllowList, clk clock.Clock) (*RuleList, error) {
 	l := &RuleList{
 		cfg: cfg,
 		db:  db,
 		kb:  dbkey.KeyBuilder{}.WithPrefix(dbkey.RuleList),
+		skb: dbkey.KeyBuilder{}.WithPrefix(dbkey.ShadowRuleList),
+		al:  al,
+		clk: clk,
 	}
 
 	return l, nil
 }
 
+// List enforced rules
 func (l *RuleList) ListRules() ([]dto.Rule, error) {
+	return l.listRules(l.kb)
+}
+
+// List rules created by analyzers in shadow mode
+func (l *RuleList) ListShadowRules() ([]dto.Rule, error) {
+	return l.listRules(l.skb)
+}
+
+func (l *RuleList) listRules(kb dbkey.KeyBuilder) ([]dto.Rule, error) {
+	now := l.clk.Now()
 	rules := make([]dto.Rule, 0)
 	err := l.db.View(func(txn *badger.Txn) error {
 		it := txn.NewIterator(badger.DefaultIteratorOptions)
 		defer it.Close()
-		keyPrefix := l.kb.Build()
+		keyPrefix := kb.Build()
 		for it.Seek(keyPrefix); it.ValidForPrefix(keyPrefix); it.Next() {
 			item := it.Item()
 			var rule dto.Rule
@@ -38,6 +57,10 @@ func (l *RuleList) ListRules() ([]dto.Rule, error) {
 			if err != nil {
 				return err
 			}
+			// Expiry is enforced by badger on wall time. Check again in case clk is not wall time
+			if !rule.ExpiresAt.After(now) {
+				continue
+			}
 			rules = append(rules, rule)
 		}
 		return nil
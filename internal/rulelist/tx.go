@@ -1,20 +1,28 @@
 package rulelist
 
 import (
+	"github.com/HT4w5/nyaago/internal/allowlist"
+	"github.com/HT4w5/nyaago/internal/clock"
 	"github.com/HT4w5/nyaago/internal/dbkey"
 	"github.com/HT4w5/nyaago/pkg/dto"
 	"github.com/dgraph-io/badger/v4"
 )
 
 type Tx struct {
-	tx *badger.Txn
-	kb dbkey.KeyBuilder
+	tx  *badger.Txn
+	kb  dbkey.KeyBuilder
+	skb dbkey.KeyBuilder
+	al  *allowlist.AllowList
+	clk clock.Clock
 }
 
 func (rl *RuleList) BeginTx() *Tx {
 	return &Tx{
-		tx: rl.db.NewTransaction(true),
-		kb: rl.kb,
+		tx:  rl.db.NewTransaction(true),
+		kb:  rl.kb,
+		skb: rl.skb,
+		al:  rl.al,
+		clk: rl.clk,
 	}
 }
 
@@ -26,13 +34,42 @@ func (tx *Tx) Discard() {
 	tx.tx.Discard()
 }
 
+// Put a rule generated by an analyzer. Existing manual rules are kept
+// and allowlisted prefixes are skipped. Shadow rules go to the shadow rule list
 func (tx *Tx) PutRule(rule dto.Rule) error {
+	if tx.al.Check(rule) {
+		return nil
+	}
+
+	kb := tx.kb
+	if rule.Shadow {
+		kb = tx.skb
+	}
+	key := kb.WithObject(rule).Build()
+	if !rule.Manual && !rule.Shadow {
+		item, err := tx.tx.Get(key)
+		if err == nil {
+			var old dto.Rule
+			err = item.Value(func(val []byte) error {
+				return old.Unmarshal(val)
+			})
+			if err != nil {
+				return err
+			}
+			if old.Manual {
+				return nil
+			}
+		} else if err != badger.ErrKeyNotFound {
+			return err
+		}
+	}
+
 	entryBytes, err := rule.Marshal()
 	if err != nil {
 		return err
 	}
 
-	entry := badger.NewEntry(tx.kb.WithObject(rule).Build(), entryBytes)
-	entry.ExpiresAt = uint64(rule.ExpiresAt.Unix())
+	entry := badger.NewEntry(key, entryBytes)
+	entry.ExpiresAt = uint64(clock.ToWall(tx.clk, rule.ExpiresAt).Unix())
 	return tx.tx.SetEntry(entry)
 }
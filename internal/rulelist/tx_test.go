@@ -0,0 +1,125 @@
+package rulelist
+
+import (
+	"net/netip"
+	"testing"
+	"time"
+
+	"github.com/HT4w5/nyaago/internal/allowlist"
+	"github.com/HT4w5/nyaago/internal/clock"
+	"github.com/HT4w5/nyaago/internal/config"
+	"github.com/HT4w5/nyaago/internal/logging"
+	"github.com/HT4w5/nyaago/pkg/dto"
+	"github.com/dgraph-io/badger/v4"
+)
+
+func TestTxPutRule(t *testing.T) {
+	prefix := netip.MustParsePrefix("192.0.2.0/24")
+	tests := []struct {
+		name      string
+		existing  *dto.Rule // Put through RuleList.PutRule before the transaction
+		put       dto.Rule
+		wantBlame string // Empty if no enforced rule is expected
+	}{
+		{
+			name:      "new analyzer rule",
+			put:       dto.Rule{Prefix: prefix, Blame: "analyzer"},
+			wantBlame: "analyzer",
+		},
+		{
+			name:      "analyzer rule replaces analyzer rule",
+			existing:  &dto.Rule{Prefix: prefix, Blame: "old"},
+			put:       dto.Rule{Prefix: prefix, Blame: "analyzer"},
+			wantBlame: "analyzer",
+		},
+		{
+			name:      "analyzer rule keeps manual rule",
+			existing:  &dto.Rule{Prefix: prefix, Blame: "manual", Manual: true},
+			put:       dto.Rule{Prefix: prefix, Blame: "analyzer"},
+			wantBlame: "manual",
+		},
+		{
+			name:      "manual rule replaces manual rule",
+			existing:  &dto.Rule{Prefix: prefix, Blame: "old", Manual: true},
+			put:       dto.Rule{Prefix: prefix, Blame: "manual", Manual: true},
+			wantBlame: "manual",
+		},
+		{
+			name:      "shadow rule leaves manual rule",
+			existing:  &dto.Rule{Prefix: prefix, Blame: "manual", Manual: true},
+			put:       dto.Rule{Prefix: prefix, Blame: "analyzer", Shadow: true},
+			wantBlame: "manual",
+		},
+		{
+			name: "allowlisted",
+			put:  dto.Rule{Prefix: netip.MustParsePrefix("203.0.113.0/24"), Blame: "analyzer"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := makeTestRuleList(t)
+			expiresAt := time.Now().Add(time.Hour)
+			if tt.existing != nil {
+				tt.existing.ExpiresAt = expiresAt
+				err := l.PutRule(*tt.existing)
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			tx := l.BeginTx()
+			tt.put.ExpiresAt = expiresAt
+			err := tx.PutRule(tt.put)
+			if err != nil {
+				tx.Discard()
+				t.Fatal(err)
+			}
+			err = tx.Commit()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			rules, err := l.ListRules()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.wantBlame == "" {
+				if len(rules) != 0 {
+					t.Errorf("ListRules() = %v, want none", rules)
+				}
+				return
+			}
+			if len(rules) != 1 || rules[0].Blame != tt.wantBlame {
+				t.Errorf("ListRules() = %v, want one rule with blame %q", rules, tt.wantBlame)
+			}
+		})
+	}
+}
+
+func makeTestRuleList(t *testing.T) *RuleList {
+	t.Helper()
+	err := logging.Init(&config.LogConfig{Access: "none"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	db, err := badger.Open(badger.DefaultOptions("").WithInMemory(true).WithLogger(nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	cfg := &config.Config{}
+	cfg.AllowList.Entries = []config.AllowEntryConfig{
+		{Prefix: config.IPPrefix{Prefix: netip.MustParsePrefix("203.0.113.0/24")}},
+	}
+	al, err := allowlist.MakeAllowList(&cfg.AllowList, db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	l, err := MakeRuleList(cfg, db, al, clock.Wall{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return l
+}
@@ -1,12 +1,27 @@
 package server
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"net/http"
+	"net/netip"
+	"strconv"
+	"time"
 
+	"github.com/HT4w5/nyaago/internal/allowlist"
+	"github.com/HT4w5/nyaago/internal/ingress"
+	"github.com/HT4w5/nyaago/internal/logging"
+	"github.com/HT4w5/nyaago/internal/rulelist"
 	"github.com/HT4w5/nyaago/pkg/dto"
+	"github.com/docker/go-units"
 	"github.com/gin-gonic/gin"
 )
 
+const (
+	defaultManualBlame = "Manual rule."
+)
+
 /*
  * API handler functions
  */
@@ -30,13 +45,316 @@ func (s *Server) HandleGetRules(c *gin.Context) {
 	c.JSON(http.StatusOK, rules)
 }
 
-// -- Record handlers --
+func (s *Server) HandleGetShadowRules(c *gin.Context) {
+	rules, err := s.rulelist.ListShadowRules()
+	if err != nil {
+		c.JSON(
+			http.StatusInternalServerError,
+			dto.MakeErrorJSON(err),
+		)
+		return
+	}
+
+	c.JSON(http.StatusOK, rules)
+}
+
+func (s *Server) HandleGetRule(c *gin.Context) {
+	prefix, err := parsePrefixParams(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, dto.MakeErrorJSON(err))
+		return
+	}
+
+	rule, err := s.rulelist.GetRule(prefix)
+	if err != nil {
+		if err == rulelist.ErrRuleNotFound {
+			c.JSON(http.StatusNotFound, dto.MakeErrorJSON(err))
+			return
+		}
+		c.JSON(http.StatusInternalServerError, dto.MakeErrorJSON(err))
+		return
+	}
+
+	c.JSON(http.StatusOK, rule)
+}
+
+// Create or override a rule. Prefix is taken from the URL if present, otherwise from the body
+func (s *Server) HandlePutRule(c *gin.Context) {
+	apply, err := parseApplyParam(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, dto.MakeErrorJSON(err))
+		return
+	}
+
+	var body dto.PutRuleJSON
+	err = c.ShouldBindJSON(&body)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, dto.MakeErrorJSON(err))
+		return
+	}
+
+	var prefix netip.Prefix
+	if c.Param("addr") != "" {
+		prefix, err = parsePrefixParams(c)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, dto.MakeErrorJSON(err))
+			return
+		}
+		if body.Prefix != "" {
+			bodyPrefix, err := netip.ParsePrefix(body.Prefix)
+			if err != nil || bodyPrefix.Masked() != prefix {
+				c.JSON(http.StatusBadRequest, dto.MakeErrorJSON(fmt.Errorf("prefix %s does not match URL", body.Prefix)))
+				return
+			}
+		}
+	} else {
+		prefix, err = netip.ParsePrefix(body.Prefix)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, dto.MakeErrorJSON(err))
+			return
+		}
+		prefix = prefix.Masked()
+	}
+
+	rule, err := s.makeManualRule(prefix, &body)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, dto.MakeErrorJSON(err))
+		return
+	}
+
+	err = s.rulelist.PutRule(rule)
+	if err != nil {
+		if err == rulelist.ErrRuleAllowListed {
+			c.JSON(http.StatusConflict, dto.MakeErrorJSON(err))
+			return
+		}
+		c.JSON(http.StatusInternalServerError, dto.MakeErrorJSON(err))
+		return
+	}
+	s.logger.Info("manual rule put", "prefix", rule.Prefix, "banned", rule.Banned, "rate_limit", rule.RateLimit, "expires_at", rule.ExpiresAt)
+
+	c.JSON(http.StatusOK, s.applyRuleChange(c, rule, apply))
+}
+
+func (s *Server) HandleDeleteRule(c *gin.Context) {
+	apply, err := parseApplyParam(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, dto.MakeErrorJSON(err))
+		return
+	}
+
+	prefix, err := parsePrefixParams(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, dto.MakeErrorJSON(err))
+		return
+	}
+
+	rule, err := s.rulelist.GetRule(prefix)
+	if err != nil {
+		if err == rulelist.ErrRuleNotFound {
+			c.JSON(http.StatusNotFound, dto.MakeErrorJSON(err))
+			return
+		}
+		c.JSON(http.StatusInternalServerError, dto.MakeErrorJSON(err))
+		return
+	}
+
+	err = s.rulelist.DelRule(prefix)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, dto.MakeErrorJSON(err))
+		return
+	}
+	s.logger.Info("rule deleted", "prefix", prefix)
+
+	c.JSON(http.StatusOK, s.applyRuleChange(c, rule, apply))
+}
+
+// -- Egress handlers --
+
+func (s *Server) HandleGetEgress(c *gin.Context) {
+	res := make([]dto.EgressJSON, 0, len(s.egress))
+	for _, t := range s.egress {
+		res = append(res, t.status())
+	}
+
+	c.JSON(http.StatusOK, res)
+}
+
+// -- Ingress handlers --
+
+func (s *Server) HandleGetIngressStats(c *gin.Context) {
+	res := dto.IngressStatsJSON{
+		Buffer:  s.buffer.Stats(),
+		Sources: make([]dto.IngressSourceStatsJSON, 0, len(s.ia)),
+	}
+	for _, ia := range s.ia {
+		res.Sources = append(res.Sources, ia.Stats())
+	}
+
+	c.JSON(http.StatusOK, res)
+}
+
+func (s *Server) HandleGetIngressRejections(c *gin.Context) {
+	res := make([]dto.IngressRejectionJSON, 0)
+	for _, ia := range s.ia {
+		if r, ok := ia.(ingress.RejectionReporter); ok {
+			res = append(res, r.Rejections()...)
+		}
+	}
+
+	c.JSON(http.StatusOK, res)
+}
+
+// -- Allowlist handlers --
+
+func (s *Server) HandleGetAllowList(c *gin.Context) {
+	c.JSON(http.StatusOK, s.allowlist.ListEntries())
+}
+
+func (s *Server) HandleGetAllowListHits(c *gin.Context) {
+	c.JSON(http.StatusOK, s.allowlist.ListHits())
+}
+
+func (s *Server) HandlePutAllowEntry(c *gin.Context) {
+	var body dto.PutAllowEntryJSON
+	err := c.ShouldBindJSON(&body)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, dto.MakeErrorJSON(err))
+		return
+	}
+
+	prefix, err := netip.ParsePrefix(body.Prefix)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, dto.MakeErrorJSON(err))
+		return
+	}
+
+	entry := dto.AllowEntry{
+		Prefix:  prefix.Masked(),
+		Comment: body.Comment,
+	}
+	err = s.allowlist.PutEntry(entry)
+	if err != nil {
+		if err == allowlist.ErrEntryStatic {
+			c.JSON(http.StatusConflict, dto.MakeErrorJSON(err))
+			return
+		}
+		c.JSON(http.StatusInternalServerError, dto.MakeErrorJSON(err))
+		return
+	}
+	s.logger.Info("allowlist entry put", "prefix", entry.Prefix, "comment", entry.Comment)
+
+	c.JSON(http.StatusOK, entry)
+}
+
+func (s *Server) HandleDeleteAllowEntry(c *gin.Context) {
+	prefix, err := parsePrefixParams(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, dto.MakeErrorJSON(err))
+		return
+	}
+
+	entry, err := s.allowlist.GetEntry(prefix)
+	if err == nil {
+		err = s.allowlist.DelEntry(prefix)
+	}
+	if err != nil {
+		switch err {
+		case allowlist.ErrEntryNotFound:
+			c.JSON(http.StatusNotFound, dto.MakeErrorJSON(err))
+		case allowlist.ErrEntryStatic:
+			c.JSON(http.StatusConflict, dto.MakeErrorJSON(err))
+		default:
+			c.JSON(http.StatusInternalServerError, dto.MakeErrorJSON(err))
+		}
+		return
+	}
+	s.logger.Info("allowlist entry deleted", "prefix", prefix)
+
+	c.JSON(http.StatusOK, entry)
+}
+
+func (s *Server) makeManualRule(prefix netip.Prefix, body *dto.PutRuleJSON) (dto.Rule, error) {
+	rule := dto.Rule{
+		Prefix: prefix,
+		Banned: body.Banned,
+		Blame:  body.Blame,
+		Source: dto.RuleSourceManual,
+		Manual: true,
+	}
+	if rule.Blame == "" {
+		rule.Blame = defaultManualBlame
+	}
+
+	if body.RateLimit != "" {
+		rateLimit, err := units.FromHumanSize(body.RateLimit)
+		if err != nil {
+			return dto.Rule{}, fmt.Errorf("bad rate limit: %w", err)
+		}
+		rule.RateLimit = rateLimit
+	}
+
+	ttl := time.Duration(s.cfg.RuleList.EntryTTL)
+	if body.TTL != "" {
+		var err error
+		ttl, err = time.ParseDuration(body.TTL)
+		if err != nil {
+			return dto.Rule{}, fmt.Errorf("bad ttl: %w", err)
+		}
+		if ttl <= 0 {
+			return dto.Rule{}, errors.New("ttl must be positive")
+		}
+	}
+	rule.ExpiresAt = time.Now().Add(ttl)
+
+	return rule, nil
+}
+
+// Parse the apply query parameter. Absent means false
+func parseApplyParam(c *gin.Context) (bool, error) {
+	applyStr := c.Query("apply")
+	if applyStr == "" {
+		return false, nil
+	}
+	apply, err := strconv.ParseBool(applyStr)
+	if err != nil {
+		return false, fmt.Errorf("bad apply parameter: %w", err)
+	}
+	return apply, nil
+}
+
+// Run egress and post_exec immediately if requested. The change is stored either way,
+// so an apply failure is reported next to the rule rather than as a failed request
+func (s *Server) applyRuleChange(c *gin.Context, rule dto.Rule, apply bool) dto.RuleChangeJSON {
+	res := dto.RuleChangeJSON{Rule: rule}
+	if !apply {
+		return res
+	}
+	// post_exec must not be killed by the client going away
+	err := s.runEgressTask(context.WithoutCancel(c.Request.Context()), s.egress)
+	if err != nil {
+		s.logger.Error("failed to apply rules", logging.SlogKeyError, err)
+		res.ApplyError = err.Error()
+		return res
+	}
+	res.Applied = true
+	return res
+}
+
+// Parse rule prefix from URL parameters. Defaults to a single address without bits
+func parsePrefixParams(c *gin.Context) (netip.Prefix, error) {
+	addr, err := netip.ParseAddr(c.Param("addr"))
+	if err != nil {
+		return netip.Prefix{}, err
+	}
 
-func (s *Server) HandleGetRecords(c *gin.Context) {
-	records := make([]dto.Record, 0, s.router.Len())
-	for v := range s.router.Iterator() {
-		records = append(records, v)
+	bits := addr.BitLen()
+	if bitsStr := c.Param("bits"); bitsStr != "" {
+		bits, err = strconv.Atoi(bitsStr)
+		if err != nil {
+			return netip.Prefix{}, fmt.Errorf("bad prefix length: %w", err)
+		}
 	}
 
-	c.JSON(http.StatusOK, records)
+	return addr.Prefix(bits)
 }
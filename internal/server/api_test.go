@@ -0,0 +1,220 @@
+package server
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/HT4w5/nyaago/internal/allowlist"
+	"github.com/HT4w5/nyaago/internal/analyzer"
+	"github.com/HT4w5/nyaago/internal/clock"
+	"github.com/HT4w5/nyaago/internal/config"
+	"github.com/HT4w5/nyaago/internal/logging"
+	"github.com/HT4w5/nyaago/internal/rulelist"
+	"github.com/HT4w5/nyaago/pkg/aclfmt"
+	"github.com/HT4w5/nyaago/pkg/dto"
+	"github.com/dgraph-io/badger/v4"
+	"github.com/gin-gonic/gin"
+)
+
+// Server with an in-memory DB and no analyzers
+func makeTestServer(t *testing.T) *Server {
+	t.Helper()
+	err := logging.Init(&config.LogConfig{Access: "none"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	db, err := badger.Open(badger.DefaultOptions("").WithInMemory(true).WithLogger(nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	cfg := &config.Config{}
+	cfg.RuleList.EntryTTL = config.Duration(time.Hour)
+	cfg.AllowList.Entries = []config.AllowEntryConfig{
+		{Prefix: config.IPPrefix{Prefix: netip.MustParsePrefix("203.0.113.0/24")}},
+	}
+
+	s := &Server{
+		cfg:    cfg,
+		db:     db,
+		logger: slog.New(slog.DiscardHandler),
+	}
+	s.allowlist, err = allowlist.MakeAllowList(&cfg.AllowList, db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.rulelist, err = rulelist.MakeRuleList(cfg, db, s.allowlist, clock.Wall{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.am = analyzer.MakeAnalyzerManager(&cfg.Analyzers, db, clock.Wall{}, 1)
+	return s
+}
+
+// Egress target writing to a temp dir and running postExec as its only post_exec
+func makeTestEgress(t *testing.T, postExec string) *egressTarget {
+	t.Helper()
+	formatter, err := aclfmt.MakeFormatter("nginx", "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &egressTarget{
+		cfg: &config.EgressConfig{
+			Name:     "test",
+			Format:   "nginx",
+			Path:     filepath.Join(t.TempDir(), "acl.conf"),
+			PostExec: []config.PostExecConfig{{Tag: "test", Cmd: postExec}},
+		},
+		formatter: formatter,
+		logger:    slog.New(slog.DiscardHandler),
+	}
+}
+
+func makeTestEngine(s *Server) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	engine := gin.New()
+	engine.PUT("/v1/rules", s.HandlePutRule)
+	engine.GET("/v1/rules/:addr", s.HandleGetRule)
+	engine.PUT("/v1/rules/:addr", s.HandlePutRule)
+	engine.DELETE("/v1/rules/:addr", s.HandleDeleteRule)
+	engine.GET("/v1/rules/:addr/:bits", s.HandleGetRule)
+	engine.PUT("/v1/rules/:addr/:bits", s.HandlePutRule)
+	engine.DELETE("/v1/rules/:addr/:bits", s.HandleDeleteRule)
+	return engine
+}
+
+func TestRuleHandlers(t *testing.T) {
+	type step struct {
+		method   string
+		path     string
+		body     string
+		wantCode int
+		// For apply=true, whether apply_error is set. The change is stored either way
+		wantApplyError bool
+	}
+	tests := []struct {
+		name     string
+		postExec string // Empty for no egress target
+		steps    []step
+	}{
+		{
+			name: "put get delete",
+			steps: []step{
+				{http.MethodPut, "/v1/rules/192.0.2.0/24", `{"banned":true}`, http.StatusOK, false},
+				{http.MethodGet, "/v1/rules/192.0.2.0/24", "", http.StatusOK, false},
+				{http.MethodGet, "/v1/rules/192.0.2.1", "", http.StatusNotFound, false},
+				{http.MethodDelete, "/v1/rules/192.0.2.0/24", "", http.StatusOK, false},
+				{http.MethodGet, "/v1/rules/192.0.2.0/24", "", http.StatusNotFound, false},
+				{http.MethodDelete, "/v1/rules/192.0.2.0/24", "", http.StatusNotFound, false},
+			},
+		},
+		{
+			name: "prefix from body",
+			steps: []step{
+				{http.MethodPut, "/v1/rules", `{"prefix":"192.0.2.7/24","rate_limit":"512KB"}`, http.StatusOK, false},
+				{http.MethodGet, "/v1/rules/192.0.2.0/24", "", http.StatusOK, false},
+			},
+		},
+		{
+			name: "bad requests",
+			steps: []step{
+				{http.MethodPut, "/v1/rules/192.0.2.0/24", `{"prefix":"198.51.100.0/24"}`, http.StatusBadRequest, false},
+				{http.MethodPut, "/v1/rules/192.0.2.0/24", `{"ttl":"-1h"}`, http.StatusBadRequest, false},
+				{http.MethodPut, "/v1/rules/192.0.2.0/24", `{"rate_limit":"fast"}`, http.StatusBadRequest, false},
+				{http.MethodPut, "/v1/rules/192.0.2.0/33", `{}`, http.StatusBadRequest, false},
+				{http.MethodPut, "/v1/rules/192.0.2.0/24?apply=maybe", `{}`, http.StatusBadRequest, false},
+				// Nothing was stored by the bad requests
+				{http.MethodGet, "/v1/rules/192.0.2.0/24", "", http.StatusNotFound, false},
+			},
+		},
+		{
+			name: "allowlisted",
+			steps: []step{
+				{http.MethodPut, "/v1/rules/203.0.113.7", `{"banned":true}`, http.StatusConflict, false},
+			},
+		},
+		{
+			name:     "apply succeeds",
+			postExec: "true",
+			steps: []step{
+				{http.MethodPut, "/v1/rules/192.0.2.0/24?apply=true", `{"banned":true}`, http.StatusOK, false},
+				{http.MethodDelete, "/v1/rules/192.0.2.0/24?apply=true", "", http.StatusOK, false},
+			},
+		},
+		{
+			name:     "apply fails",
+			postExec: "false",
+			steps: []step{
+				{http.MethodPut, "/v1/rules/192.0.2.0/24?apply=true", `{"banned":true}`, http.StatusOK, true},
+				{http.MethodGet, "/v1/rules/192.0.2.0/24", "", http.StatusOK, false},
+				{http.MethodDelete, "/v1/rules/192.0.2.0/24?apply=true", "", http.StatusOK, true},
+				{http.MethodGet, "/v1/rules/192.0.2.0/24", "", http.StatusNotFound, false},
+				{http.MethodPut, "/v1/rules/192.0.2.0/24?apply=false", `{"banned":true}`, http.StatusOK, false},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := makeTestServer(t)
+			if tt.postExec != "" {
+				s.egress = []*egressTarget{makeTestEgress(t, tt.postExec)}
+			}
+			engine := makeTestEngine(s)
+
+			for _, st := range tt.steps {
+				req := httptest.NewRequest(st.method, st.path, strings.NewReader(st.body))
+				res := httptest.NewRecorder()
+				engine.ServeHTTP(res, req)
+				if res.Code != st.wantCode {
+					t.Fatalf("%s %s = %d, want %d: %s", st.method, st.path, res.Code, st.wantCode, res.Body.String())
+				}
+				if res.Code != http.StatusOK || !strings.HasSuffix(st.path, "apply=true") {
+					continue
+				}
+				var change struct {
+					Applied    bool   `json:"applied"`
+					ApplyError string `json:"apply_error"`
+				}
+				err := json.Unmarshal(res.Body.Bytes(), &change)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if change.Applied == st.wantApplyError || (change.ApplyError != "") != st.wantApplyError {
+					t.Errorf("%s %s = %s, want apply error %v", st.method, st.path, res.Body.String(), st.wantApplyError)
+				}
+			}
+		})
+	}
+}
+
+func TestPutRuleIsManual(t *testing.T) {
+	s := makeTestServer(t)
+	engine := makeTestEngine(s)
+
+	req := httptest.NewRequest(http.MethodPut, "/v1/rules/192.0.2.0/24", strings.NewReader(`{"banned":true,"ttl":"10m"}`))
+	res := httptest.NewRecorder()
+	engine.ServeHTTP(res, req)
+	if res.Code != http.StatusOK {
+		t.Fatalf("PUT = %d: %s", res.Code, res.Body.String())
+	}
+
+	rule, err := s.rulelist.GetRule(netip.MustParsePrefix("192.0.2.0/24"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !rule.Manual || rule.Source != dto.RuleSourceManual || rule.Blame != defaultManualBlame {
+		t.Errorf("stored rule = %+v, want manual rule with default blame", rule)
+	}
+	if ttl := time.Until(rule.ExpiresAt); ttl <= 9*time.Minute || ttl > 10*time.Minute {
+		t.Errorf("stored rule expires in %s, want 10m", ttl)
+	}
+}
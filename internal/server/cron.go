@@ -2,19 +2,57 @@ package server
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"time"
 
 	"github.com/go-co-op/gocron/v2"
 )
 
-func (s *Server) setupCronJobs() {
-	s.cron.NewJob(
-		gocron.DurationJob(time.Duration(s.cfg.Egress.Interval)),
-		gocron.NewTask(s.runEgressTask),
-	)
+// Schedule each egress target independently
+func (s *Server) setupCronJobs() error {
+	for _, t := range s.egress {
+		_, err := s.cron.NewJob(
+			gocron.DurationJob(time.Duration(t.cfg.Interval)),
+			gocron.NewTask(s.runEgressTask, []*egressTarget{t}),
+			gocron.WithName(t.cfg.Name),
+		)
+		if err != nil {
+			return fmt.Errorf("failed to schedule egress %s: %w", t.cfg.Name, err)
+		}
+	}
+	return nil
 }
 
-func (s *Server) runEgressTask(ctx context.Context) {
-	s.writeACL()
-	s.postExec(ctx)
+// Render and apply egress targets. Returns the errors of targets that failed to apply
+func (s *Server) runEgressTask(ctx context.Context, targets []*egressTarget) error {
+	s.egressMu.Lock()
+	defer s.egressMu.Unlock()
+
+	// Collect analyzer findings right before rendering
+	s.am.SaveRules(s.rulelist)
+	var errs []error
+	for _, t := range targets {
+		ok, err := s.writeACL(ctx, t)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("egress %s: %w", t.cfg.Name, err))
+			continue
+		}
+		if t.cfg.Shadow {
+			t.logger.Info("shadow mode, skipping postexec")
+			continue
+		}
+		if !ok {
+			// Unchanged, logged by writeACL
+			continue
+		}
+		err = t.postExec(ctx)
+		if err != nil {
+			// Not applied, retry on the next run even if the rule set is unchanged
+			errs = append(errs, fmt.Errorf("egress %s: %w", t.cfg.Name, err))
+			continue
+		}
+		t.markApplied()
+	}
+	return errors.Join(errs...)
 }
@@ -1,37 +1,334 @@
 package server
 
 import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/netip"
 	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
 
+	"github.com/HT4w5/nyaago/internal/config"
 	"github.com/HT4w5/nyaago/internal/logging"
 	"github.com/HT4w5/nyaago/pkg/aclfmt"
+	"github.com/HT4w5/nyaago/pkg/dto"
 	"github.com/HT4w5/nyaago/pkg/meta"
 )
 
-func (s *Server) writeACL() {
-	s.logger.Info("writing ACL config")
+const (
+	slogKeyEgress = "egress"
+)
+
+// A named egress target with its own formatter, path, schedule and post_exec chain
+type egressTarget struct {
+	cfg       *config.EgressConfig
+	formatter aclfmt.Formatter
+	logger    *slog.Logger
+
+	mu            sync.Mutex
+	pending       []byte    // Digest written but not yet applied by post_exec
+	pendingExpiry time.Time // Expiry written but not yet applied by post_exec
+	digest        []byte    // Digest of the last applied rule set
+	expiry        time.Time // Earliest timeout in the applied file. Zero if none
+	lastApplied   time.Time // Last time the file was written and post_exec ran
+	lastChanged   time.Time // Last time the applied rule set changed
+}
+
+func (s *Server) makeEgressTargets() error {
+	s.egress = make([]*egressTarget, 0, len(s.cfg.Egress))
+	for i := range s.cfg.Egress {
+		cfg := &s.cfg.Egress[i]
+		formatter, err := aclfmt.MakeFormatter(cfg.Format, meta.GetMetadataSingleLine())
+		if err != nil {
+			return fmt.Errorf("failed to create formatter for egress %s: %w", cfg.Name, err)
+		}
+		s.egress = append(s.egress, &egressTarget{
+			cfg:       cfg,
+			formatter: formatter,
+			logger:    s.logger.With(slogKeyEgress, cfg.Name),
+		})
+	}
+	return nil
+}
+
+// Check whether a rule passes the target's filter
+func (t *egressTarget) selects(rule dto.Rule) bool {
+	f := &t.cfg.Filter
+	switch f.Kind {
+	case "ban":
+		if !rule.Banned {
+			return false
+		}
+	case "limit":
+		if rule.Banned {
+			return false
+		}
+	}
+	if len(f.Sources) > 0 && !slices.Contains(f.Sources, rule.Source) {
+		return false
+	}
+	if f.Blame != nil && !f.Blame.MatchString(rule.Blame) {
+		return false
+	}
+	return true
+}
+
+func (t *egressTarget) filter(rules []dto.Rule) []dto.Rule {
+	res := make([]dto.Rule, 0, len(rules))
+	for _, v := range rules {
+		if t.selects(v) {
+			res = append(res, v)
+		}
+	}
+	return res
+}
 
-	// Create formatter
-	formatter, err := aclfmt.MakeFormatter(s.cfg.Egress.Format, meta.GetMetadataSingleLine())
+// Render rules for a target. Returns false if the ACL config was not replaced,
+// in which case post_exec must not run. Errors are returned for failures only,
+// an unchanged rule set or shadow mode is not an error
+func (s *Server) writeACL(ctx context.Context, t *egressTarget) (bool, error) {
+	rules, err := s.rulelist.ListRules()
 	if err != nil {
-		s.logger.Error("failed to create formatter", logging.SlogKeyError, err)
+		t.logger.Error("failed to get rules", logging.SlogKeyError, err)
+		return false, fmt.Errorf("failed to get rules: %w", err)
+	}
+	// Entries may have been added after a rule was created
+	rules = t.filter(s.allowlist.Filter(rules))
+
+	ok := false
+	var writeErr error
+	if t.cfg.Shadow {
+		t.logger.Info("shadow mode, skipping ACL config", "path", t.cfg.Path)
+	} else if digest := t.formatter.Digest(rules); !t.needsApply(digest) {
+		t.logger.Info("rule set unchanged, skipping ACL config", "path", t.cfg.Path)
+	} else {
+		t.logger.Info("writing ACL config", "path", t.cfg.Path)
+		writeErr = t.writeRules(ctx, rules, t.cfg.Path, t.cfg.Validate)
+		if writeErr != nil {
+			t.logger.Error("failed to write config", logging.SlogKeyError, writeErr, "path", t.cfg.Path, "formatter_type", t.cfg.Format)
+			writeErr = fmt.Errorf("failed to write config: %w", writeErr)
+		} else {
+			t.mu.Lock()
+			t.pending = digest
+			t.pendingExpiry = t.formatter.Expiry(rules)
+			t.mu.Unlock()
+			ok = true
+		}
+	}
+
+	if t.cfg.ShadowPath == "" {
+		return ok, writeErr
 	}
 
-	// Open file for write
-	f, err := os.OpenFile(s.cfg.Egress.Path, os.O_WRONLY|os.O_TRUNC|os.O_CREATE, 0664)
+	// Shadow output is informational, its errors are only logged
+	shadowRules, err := s.rulelist.ListShadowRules()
 	if err != nil {
-		s.logger.Error("failed to open config file", logging.SlogKeyError, err, "path", s.cfg.Egress.Path)
-		return
+		t.logger.Error("failed to get shadow rules", logging.SlogKeyError, err)
+		return ok, writeErr
+	}
+	shadowRules = t.filter(s.allowlist.Filter(shadowRules))
+	err = t.writeRules(ctx, mergeShadowRules(rules, shadowRules), t.cfg.ShadowPath, nil)
+	if err != nil {
+		t.logger.Error("failed to write shadow config", logging.SlogKeyError, err, "path", t.cfg.ShadowPath, "formatter_type", t.cfg.Format)
 	}
+	return ok, writeErr
+}
 
-	rules, err := s.rulelist.ListRules()
+// Check whether a rendered rule set differs from the applied one or max_interval has passed.
+// Digests leave out expiry, so the file is also rewritten when one of its timeouts would
+// lapse before the next run, as analyzers may have pushed that expiry forward since
+func (t *egressTarget) needsApply(digest []byte) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if !bytes.Equal(digest, t.digest) {
+		return true
+	}
+	if !t.expiry.IsZero() && time.Until(t.expiry) <= time.Duration(t.cfg.Interval) {
+		return true
+	}
+	return t.cfg.MaxInterval > 0 && time.Since(t.lastApplied) >= time.Duration(t.cfg.MaxInterval)
+}
+
+// Record the written rule set as applied. Called after post_exec
+func (t *egressTarget) markApplied() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	now := time.Now()
+	if !bytes.Equal(t.pending, t.digest) {
+		t.lastChanged = now
+	}
+	t.digest = t.pending
+	t.expiry = t.pendingExpiry
+	t.lastApplied = now
+}
+
+func (t *egressTarget) status() dto.EgressJSON {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	status := dto.EgressJSON{
+		Name:   t.cfg.Name,
+		Format: t.cfg.Format,
+		Path:   t.cfg.Path,
+		Shadow: t.cfg.Shadow,
+		Digest: hex.EncodeToString(t.digest),
+	}
+	if !t.lastApplied.IsZero() {
+		status.LastApplied = t.lastApplied.Format(time.RFC3339)
+	}
+	if !t.lastChanged.IsZero() {
+		status.LastChanged = t.lastChanged.Format(time.RFC3339)
+	}
+	return status
+}
+
+// Atomically replace path with rendered rules. If validate is set, it runs with the
+// new file in place and the previous file is restored when it fails
+func (t *egressTarget) writeRules(ctx context.Context, rules []dto.Rule, path string, validate *config.PostExecConfig) error {
+	// Write to a temp file in the same directory so rename stays atomic
+	dir := filepath.Dir(path)
+	f, err := os.CreateTemp(dir, "."+filepath.Base(path)+".*.tmp")
 	if err != nil {
-		s.logger.Error("failed to get rules", logging.SlogKeyError, err)
-		return
+		return fmt.Errorf("failed to create temp file: %w", err)
 	}
-	err = formatter.Marshal(rules, f)
+	tmpPath := f.Name()
+	defer os.Remove(tmpPath) // No-op after rename
+
+	err = t.formatter.Marshal(rules, f)
+	if err == nil {
+		err = f.Chmod(0664)
+	}
+	if err == nil {
+		err = f.Sync()
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+
+	if validate == nil {
+		err = os.Rename(tmpPath, path)
+		if err != nil {
+			return err
+		}
+		syncDir(dir)
+		return nil
+	}
+
+	// Keep a copy of the previous file for rollback. The live file stays in place
+	// until the new one is renamed over it
+	prevPath := path + ".prev"
+	hasPrev, err := backupFile(path, prevPath)
+	if err != nil {
+		return fmt.Errorf("failed to back up previous file: %w", err)
+	}
+
+	err = os.Rename(tmpPath, path)
+	if err != nil {
+		if hasPrev {
+			os.Remove(prevPath)
+		}
+		return err
+	}
+
+	t.logger.Info("validating ACL config", "tag", validate.Tag)
+	cmd := exec.CommandContext(ctx, validate.Cmd, validate.Args...)
+	if validate.Cwd != "" {
+		cmd.Dir = validate.Cwd
+	}
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		// Roll back
+		var rollbackErr error
+		if hasPrev {
+			rollbackErr = os.Rename(prevPath, path)
+		} else {
+			rollbackErr = os.Remove(path)
+		}
+		if rollbackErr != nil {
+			t.logger.Error("failed to roll back ACL config", logging.SlogKeyError, rollbackErr, "path", path)
+		}
+		syncDir(dir)
+		return fmt.Errorf("validation %s failed: %w: %s", validate.Tag, err, strings.TrimSpace(string(out)))
+	}
+
+	if hasPrev {
+		os.Remove(prevPath)
+	}
+	syncDir(dir)
+	return nil
+}
+
+// Link (or copy, where hard links are unsupported) path to prevPath.
+// Returns false if there is no file at path
+func backupFile(path string, prevPath string) (bool, error) {
+	err := os.Remove(prevPath)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return false, err
+	}
+	err = os.Link(path, prevPath)
+	if err == nil {
+		return true, nil
+	}
+	if errors.Is(err, os.ErrNotExist) {
+		return false, nil
+	}
+
+	src, err := os.Open(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return false, nil
+		}
+		return false, err
+	}
+	defer src.Close()
+	dst, err := os.OpenFile(prevPath, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0664)
+	if err != nil {
+		return false, err
+	}
+	_, err = io.Copy(dst, src)
+	if closeErr := dst.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(prevPath)
+		return false, err
+	}
+	return true, nil
+}
+
+// Persist renames. Errors are ignored as not all platforms support syncing directories
+func syncDir(dir string) {
+	d, err := os.Open(dir)
 	if err != nil {
-		s.logger.Error("failed to write config", logging.SlogKeyError, err, "path", s.cfg.Egress.Path, "formatter_type", s.cfg.Egress.Format)
 		return
 	}
+	d.Sync()
+	d.Close()
+}
+
+// Rules that would be enforced with shadow mode off. Enforced rules take precedence
+func mergeShadowRules(rules []dto.Rule, shadowRules []dto.Rule) []dto.Rule {
+	merged := make([]dto.Rule, 0, len(rules)+len(shadowRules))
+	merged = append(merged, rules...)
+	enforced := make(map[netip.Prefix]struct{}, len(rules))
+	for _, v := range rules {
+		enforced[v.Prefix] = struct{}{}
+	}
+	for _, v := range shadowRules {
+		if _, ok := enforced[v.Prefix]; !ok {
+			merged = append(merged, v)
+		}
+	}
+	return merged
 }
@@ -0,0 +1,311 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/HT4w5/nyaago/internal/config"
+	"github.com/HT4w5/nyaago/pkg/aclfmt"
+	"github.com/HT4w5/nyaago/pkg/dto"
+)
+
+func TestWriteRules(t *testing.T) {
+	tests := []struct {
+		name     string
+		previous string // Empty for no existing file
+		validate string // Shell script, empty to skip validation
+		wantErr  bool
+		wantNew  bool // Whether path holds the new rules afterwards
+		wantGone bool // Whether path is absent afterwards
+	}{
+		{
+			name:    "write without validation",
+			wantNew: true,
+		},
+		{
+			name:     "replace without validation",
+			previous: "old\n",
+			wantNew:  true,
+		},
+		{
+			name:     "validation passes",
+			previous: "old\n",
+			validate: `grep -q 10.0.0.0/24 "$0"`,
+			wantNew:  true,
+		},
+		{
+			name:     "validation fails with previous file",
+			previous: "old\n",
+			validate: "exit 1",
+			wantErr:  true,
+		},
+		{
+			name:     "validation fails without previous file",
+			validate: "exit 1",
+			wantErr:  true,
+			wantGone: true,
+		},
+	}
+
+	formatter, err := aclfmt.MakeFormatter("nginx", "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	rules := []dto.Rule{{Prefix: netip.MustParsePrefix("10.0.0.0/24"), Banned: true}}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			path := filepath.Join(dir, "acl.conf")
+			if tt.previous != "" {
+				err := os.WriteFile(path, []byte(tt.previous), 0664)
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+			var validate *config.PostExecConfig
+			if tt.validate != "" {
+				validate = &config.PostExecConfig{
+					Tag:  "test",
+					Cmd:  "sh",
+					Args: []string{"-c", tt.validate, path},
+				}
+			}
+
+			target := &egressTarget{
+				formatter: formatter,
+				logger:    slog.New(slog.DiscardHandler),
+			}
+			err := target.writeRules(context.Background(), rules, path, validate)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("writeRules() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			data, err := os.ReadFile(path)
+			switch {
+			case tt.wantGone:
+				if !errors.Is(err, os.ErrNotExist) {
+					t.Fatalf("path exists after rollback: %v", err)
+				}
+			case err != nil:
+				t.Fatal(err)
+			case tt.wantNew && !strings.Contains(string(data), "10.0.0.0/24"):
+				t.Errorf("path = %q, want new rules", data)
+			case !tt.wantNew && string(data) != tt.previous:
+				t.Errorf("path = %q, want restored %q", data, tt.previous)
+			}
+
+			// Only the live file may remain
+			entries, err := os.ReadDir(dir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, v := range entries {
+				if v.Name() != "acl.conf" {
+					t.Errorf("leftover file %s", v.Name())
+				}
+			}
+		})
+	}
+}
+
+func TestBackupFileKeepsLiveFile(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "acl.conf")
+	prevPath := path + ".prev"
+	err := os.WriteFile(path, []byte("live\n"), 0664)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Stale backup from an interrupted run
+	err = os.WriteFile(prevPath, []byte("stale\n"), 0664)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ok, err := backupFile(path, prevPath)
+	if err != nil || !ok {
+		t.Fatalf("backupFile() = %v, %v", ok, err)
+	}
+	for _, p := range []string{path, prevPath} {
+		data, err := os.ReadFile(p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(data) != "live\n" {
+			t.Errorf("%s = %q, want %q", filepath.Base(p), data, "live\n")
+		}
+	}
+
+	ok, err = backupFile(filepath.Join(dir, "missing"), filepath.Join(dir, "missing.prev"))
+	if err != nil || ok {
+		t.Errorf("backupFile() on missing file = %v, %v, want false, nil", ok, err)
+	}
+}
+
+// Apply a rule set, then check whether the next one needs applying
+func TestNeedsApply(t *testing.T) {
+	const interval = time.Minute
+	now := time.Now()
+	ban := func(prefix string, ttl time.Duration) dto.Rule {
+		return dto.Rule{Prefix: netip.MustParsePrefix(prefix), Banned: true, ExpiresAt: now.Add(ttl)}
+	}
+
+	tests := []struct {
+		name         string
+		maxInterval  time.Duration
+		sinceApplied time.Duration // Age of the first apply when checking the next rule set
+		applied      []dto.Rule
+		next         []dto.Rule
+		want         bool
+	}{
+		{
+			name:    "unchanged",
+			applied: []dto.Rule{ban("192.0.2.0/24", time.Hour)},
+			next:    []dto.Rule{ban("192.0.2.0/24", time.Hour)},
+			want:    false,
+		},
+		{
+			name:    "changed",
+			applied: []dto.Rule{ban("192.0.2.0/24", time.Hour)},
+			next:    []dto.Rule{ban("192.0.2.0/24", time.Hour), ban("198.51.100.0/24", time.Hour)},
+			want:    true,
+		},
+		{
+			name:         "max_interval elapsed",
+			maxInterval:  10 * time.Minute,
+			sinceApplied: 10 * time.Minute,
+			applied:      []dto.Rule{ban("192.0.2.0/24", time.Hour)},
+			next:         []dto.Rule{ban("192.0.2.0/24", time.Hour)},
+			want:         true,
+		},
+		{
+			name:         "max_interval not elapsed",
+			maxInterval:  10 * time.Minute,
+			sinceApplied: 5 * time.Minute,
+			applied:      []dto.Rule{ban("192.0.2.0/24", time.Hour)},
+			next:         []dto.Rule{ban("192.0.2.0/24", time.Hour)},
+			want:         false,
+		},
+		{
+			name:         "max_interval disabled",
+			sinceApplied: 24 * time.Hour,
+			applied:      []dto.Rule{ban("192.0.2.0/24", 48*time.Hour)},
+			next:         []dto.Rule{ban("192.0.2.0/24", 48*time.Hour)},
+			want:         false,
+		},
+		{
+			name:    "expiry pushed forward",
+			applied: []dto.Rule{ban("192.0.2.0/24", time.Hour)},
+			next:    []dto.Rule{ban("192.0.2.0/24", 2*time.Hour)},
+			want:    false,
+		},
+		{
+			name:    "timeout lapses before the next run",
+			applied: []dto.Rule{ban("192.0.2.0/24", 30*time.Second)},
+			next:    []dto.Rule{ban("192.0.2.0/24", time.Hour)},
+			want:    true,
+		},
+	}
+
+	formatter, err := aclfmt.MakeFormatter("nftables", "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			target := &egressTarget{
+				cfg: &config.EgressConfig{
+					Name:        "test",
+					Interval:    config.Duration(interval),
+					MaxInterval: config.Duration(tt.maxInterval),
+				},
+				formatter: formatter,
+			}
+			if !target.needsApply(formatter.Digest(tt.applied)) {
+				t.Fatal("needsApply() = false before the first apply")
+			}
+			target.pending = formatter.Digest(tt.applied)
+			target.pendingExpiry = formatter.Expiry(tt.applied)
+			target.markApplied()
+			target.lastApplied = target.lastApplied.Add(-tt.sinceApplied)
+
+			if got := target.needsApply(formatter.Digest(tt.next)); got != tt.want {
+				t.Errorf("needsApply() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEgressFilter(t *testing.T) {
+	rules := []dto.Rule{
+		{Prefix: netip.MustParsePrefix("192.0.2.0/24"), Banned: true, Source: "leaky_bucket", Blame: "bucket overflow"},
+		{Prefix: netip.MustParsePrefix("198.51.100.0/24"), RateLimit: 1024, Source: "request_frequency", Blame: "too many requests"},
+		{Prefix: netip.MustParsePrefix("203.0.113.0/24"), Banned: true, Source: dto.RuleSourceManual, Blame: "abuse report"},
+	}
+
+	tests := []struct {
+		name   string
+		filter string
+		want   []string
+	}{
+		{
+			name:   "no filter",
+			filter: `{}`,
+			want:   []string{"192.0.2.0/24", "198.51.100.0/24", "203.0.113.0/24"},
+		},
+		{
+			name:   "bans",
+			filter: `{"kind": "ban"}`,
+			want:   []string{"192.0.2.0/24", "203.0.113.0/24"},
+		},
+		{
+			name:   "rate limits",
+			filter: `{"kind": "limit"}`,
+			want:   []string{"198.51.100.0/24"},
+		},
+		{
+			name:   "sources",
+			filter: `{"sources": ["request_frequency", "manual"]}`,
+			want:   []string{"198.51.100.0/24", "203.0.113.0/24"},
+		},
+		{
+			name:   "blame",
+			filter: `{"blame": "^too many"}`,
+			want:   []string{"198.51.100.0/24"},
+		},
+		{
+			name:   "all fields must match",
+			filter: `{"kind": "ban", "sources": ["manual", "request_frequency"]}`,
+			want:   []string{"203.0.113.0/24"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &config.EgressConfig{Name: "test"}
+			err := json.Unmarshal([]byte(tt.filter), &cfg.Filter)
+			if err != nil {
+				t.Fatal(err)
+			}
+			target := &egressTarget{cfg: cfg}
+
+			var got []string
+			for _, v := range target.filter(rules) {
+				got = append(got, v.Prefix.String())
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("filter() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
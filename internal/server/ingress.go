@@ -2,24 +2,42 @@ package server
 
 import (
 	"context"
-
-	"github.com/HT4w5/nyaago/pkg/dto"
 )
 
 func (s *Server) runIngressWorker(ctx context.Context, cancel context.CancelFunc) {
-	s.logger.Info("starting ingress worker")
-	requestChan := make(chan dto.Request)
+	s.logger.Info("starting ingress worker", "workers", s.buffer.Len())
+
+	// Start ingress adapters, all feeding the same buffer
+	for _, ia := range s.ia {
+		s.ingressWg.Add(1)
+		go func() {
+			defer s.ingressWg.Done()
+			ia.Start(ctx, s.buffer, cancel)
+		}()
+	}
 
-	// Start ingress adapter
-	go s.ia.Start(ctx, requestChan, cancel)
+	// Workers outlive the adapters: once every adapter has stopped pushing, they drain
+	// what is queued and exit. Queued requests may already be acked or checkpointed
+	workerCtx, workerCancel := context.WithCancel(context.Background())
+	go func() {
+		<-ctx.Done()
+		s.ingressWg.Wait()
+		workerCancel()
+	}()
 
-	// Worker loop
-	for {
-		select {
-		case <-ctx.Done():
-			return
-		case req := <-requestChan:
-			s.router.ProcessRequest(req)
-		}
+	// One analysis worker per shard, so requests of a client are processed in order by one goroutine
+	for i := range s.buffer.Len() {
+		s.analysisWg.Add(1)
+		go func() {
+			defer s.analysisWg.Done()
+			buf := s.buffer.Shard(i)
+			for {
+				req, ok := buf.Pop(workerCtx)
+				if !ok {
+					return
+				}
+				s.router.ProcessRequest(req)
+			}
+		}()
 	}
 }
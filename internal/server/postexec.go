@@ -2,15 +2,19 @@ package server
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"os/exec"
 
 	"github.com/HT4w5/nyaago/internal/logging"
 )
 
-func (s *Server) postExec(ctx context.Context) {
-	total := len(s.cfg.Egress.PostExec)
-	for i, v := range s.cfg.Egress.PostExec {
-		s.logger.Info("running postexec", "total", total, "current", i, "tag", v.Tag)
+// Run the post_exec chain. Returns the errors of all failed commands
+func (t *egressTarget) postExec(ctx context.Context) error {
+	var errs []error
+	total := len(t.cfg.PostExec)
+	for i, v := range t.cfg.PostExec {
+		t.logger.Info("running postexec", "total", total, "current", i, "tag", v.Tag)
 
 		cmd := exec.CommandContext(ctx, v.Cmd, v.Args...)
 		if v.Cwd != "" {
@@ -19,7 +23,9 @@ func (s *Server) postExec(ctx context.Context) {
 
 		err := cmd.Run()
 		if err != nil {
-			s.logger.Error("failed to run postexec", "tag", v.Tag, logging.SlogKeyError, err)
+			t.logger.Error("failed to run postexec", "tag", v.Tag, logging.SlogKeyError, err)
+			errs = append(errs, fmt.Errorf("postexec %s: %w", v.Tag, err))
 		}
 	}
+	return errors.Join(errs...)
 }
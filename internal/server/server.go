@@ -4,10 +4,16 @@ import (
 	"context"
 	"fmt"
 	"log/slog"
+	"sync"
 
+	"github.com/HT4w5/nyaago/internal/allowlist"
+	"github.com/HT4w5/nyaago/internal/analyzer"
+	"github.com/HT4w5/nyaago/internal/clock"
 	"github.com/HT4w5/nyaago/internal/config"
 	"github.com/HT4w5/nyaago/internal/ingress"
 	"github.com/HT4w5/nyaago/internal/logging"
+	"github.com/HT4w5/nyaago/internal/pipeline"
+	"github.com/HT4w5/nyaago/internal/router"
 	"github.com/HT4w5/nyaago/internal/rulelist"
 	"github.com/dgraph-io/badger/v4"
 	"github.com/go-co-op/gocron/v2"
@@ -22,12 +28,20 @@ const (
 )
 
 type Server struct {
-	cfg      *config.Config
-	db       *badger.DB
-	rulelist *rulelist.RuleList
-	ia       ingress.IngressAdapter
-	cron     gocron.Scheduler
-	logger   *slog.Logger
+	cfg        *config.Config
+	db         *badger.DB
+	rulelist   *rulelist.RuleList
+	allowlist  *allowlist.AllowList
+	am         *analyzer.AnalyzerManager
+	router     *router.Router
+	ia         []ingress.IngressAdapter
+	buffer     *pipeline.Sharded
+	egress     []*egressTarget
+	cron       gocron.Scheduler
+	egressMu   sync.Mutex     // Serializes scheduled and API triggered egress
+	ingressWg  sync.WaitGroup // Running ingress adapters
+	analysisWg sync.WaitGroup // Running analysis workers
+	logger     *slog.Logger
 }
 
 var server *Server
@@ -44,6 +58,7 @@ func GetServer(cfg *config.Config) (*Server, error) {
 	var err error
 	// Create logger
 	logger := logging.GetLogger()
+	s.logger = logger.With(logging.SlogKeyModule, slogModuleNameServer).WithGroup(slogGroupNameServer)
 
 	// Open DB
 	s.db, err = badger.Open(badger.DefaultOptions(s.cfg.DB.Dir))
@@ -51,12 +66,27 @@ func GetServer(cfg *config.Config) (*Server, error) {
 		return nil, fmt.Errorf("failed to open db: %w", err)
 	}
 
+	// Create AllowList
+	s.allowlist, err = allowlist.MakeAllowList(&cfg.AllowList, s.db)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create allowlist: %w", err)
+	}
+
 	// Create RuleList
-	s.rulelist, err = rulelist.MakeRuleList(cfg, s.db)
+	s.rulelist, err = rulelist.MakeRuleList(cfg, s.db, s.allowlist, clock.Wall{})
 	if err != nil {
 		return nil, fmt.Errorf("failed to create rulelist: %w", err)
 	}
 
+	// Create analyzer manager
+	s.am = analyzer.MakeAnalyzerManager(&cfg.Analyzers, s.db, clock.Wall{}, cfg.Pipeline.Workers)
+
+	// Create router
+	s.router, err = router.MakeRouter(&cfg.Router, s.am)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create router: %w", err)
+	}
+
 	// Create cron scheduler
 	s.cron, err = gocron.NewScheduler(
 		gocron.WithLogger(logger.With(logging.SlogKeyModule, slogModuleNameCron).WithGroup(slogGroupNameCron)),
@@ -64,15 +94,28 @@ func GetServer(cfg *config.Config) (*Server, error) {
 	if err != nil {
 		return nil, fmt.Errorf("failed to create cron scheduler: %w", err)
 	}
-	s.setupCronJobs()
 
-	// Create ingress adapter
-	s.ia, err = ingress.MakeIngressAdapter(&cfg.Ingress)
+	// Create egress targets
+	err = s.makeEgressTargets()
 	if err != nil {
-		return nil, fmt.Errorf("failed to create ingress adapter: %w", err)
+		return nil, err
+	}
+	err = s.setupCronJobs()
+	if err != nil {
+		return nil, err
 	}
 
-	s.logger = logger.With(logging.SlogKeyModule, slogModuleNameServer).WithGroup(slogGroupNameServer)
+	// Create buffer between ingress and analysis
+	s.buffer, err = pipeline.MakeSharded(&cfg.Pipeline)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create pipeline buffer: %w", err)
+	}
+
+	// Create ingress adapters
+	s.ia, err = ingress.MakeIngressAdapters(cfg.Ingress, s.db)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create ingress adapters: %w", err)
+	}
 
 	server = s
 	return s, nil
@@ -81,13 +124,24 @@ func GetServer(cfg *config.Config) (*Server, error) {
 func (s *Server) Start(ctx context.Context, cancel context.CancelFunc) {
 	s.logger.Info("starting")
 
-	// Create egress file
-	s.writeACL()
+	// Analyzers
+	err := s.am.Start(ctx)
+	if err != nil {
+		s.logger.Error("failed to start analyzer manager", logging.SlogKeyError, err)
+		cancel()
+		return
+	}
+
+	// Create egress files
+	s.am.SaveRules(s.rulelist)
+	for _, t := range s.egress {
+		s.writeACL(ctx, t)
+	}
 	// Cron
 	s.cron.Start()
 
-	// Ingress worker
-	go s.runIngressWorker(ctx, cancel)
+	// Ingress adapters and analysis workers
+	s.runIngressWorker(ctx, cancel)
 }
 
 func (s *Server) Shutdown(ctx context.Context) {
@@ -98,6 +152,19 @@ func (s *Server) Shutdown(ctx context.Context) {
 		s.logger.Error("failed to shutdown gocron scheduler", logging.SlogKeyError, err)
 	}
 
+	// Let adapters save state and workers finish their request before closing the DB
+	done := make(chan struct{})
+	go func() {
+		s.ingressWg.Wait()
+		s.analysisWg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-ctx.Done():
+		s.logger.Warn("timed out waiting for ingress adapters and analysis workers")
+	}
+
 	s.db.Close()
 
 	s.logger.Info("exiting")
@@ -3,12 +3,15 @@ package aclfmt
 import (
 	"fmt"
 	"io"
+	"time"
 
 	"github.com/HT4w5/nyaago/pkg/dto"
 )
 
 type Formatter interface {
 	Marshal(rules []dto.Rule, w io.Writer) error
+	Digest(rules []dto.Rule) []byte    // Hash of the output ignoring generation time
+	Expiry(rules []dto.Rule) time.Time // Earliest timeout in the output. Zero if it carries none
 	Info() string
 }
 
@@ -16,6 +19,10 @@ func MakeFormatter(format string, info string) (Formatter, error) {
 	switch format {
 	case "nginx":
 		return makeNginxFormatter(info), nil
+	case "nftables":
+		return makeNftablesFormatter(info), nil
+	case "ipset":
+		return makeIpsetFormatter(info), nil
 	default:
 		return nil, fmt.Errorf("unsupported formatter type %s", format)
 	}
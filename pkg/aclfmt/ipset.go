@@ -0,0 +1,104 @@
+package aclfmt
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/HT4w5/nyaago/pkg/dto"
+)
+
+const (
+	ipsetSetV4 = "nyaago_banned_v4"
+	ipsetSetV6 = "nyaago_banned_v6"
+
+	ipsetMaxTimeout = 2147483 // Kernel limit in seconds
+
+	// Fill temporary sets and swap them in atomically
+	ipsetTemplatePrefix = `%[1]s
+create %[2]s hash:net family inet timeout 0 -exist
+create %[3]s hash:net family inet6 timeout 0 -exist
+create %[2]s_tmp hash:net family inet timeout 0 -exist
+create %[3]s_tmp hash:net family inet6 timeout 0 -exist
+flush %[2]s_tmp
+flush %[3]s_tmp
+`
+	ipsetTemplateEntry  = "add %s_tmp %s timeout %d -exist\n" // set, prefix, timeout
+	ipsetTemplateSuffix = `swap %[1]s_tmp %[1]s
+swap %[2]s_tmp %[2]s
+destroy %[1]s_tmp
+destroy %[2]s_tmp
+`
+)
+
+// Emits an ipset restore script for sets nyaago_banned_v4 and nyaago_banned_v6.
+// Only banned rules are exported
+type IpsetFormatter struct {
+	info string
+}
+
+func makeIpsetFormatter(info string) *IpsetFormatter {
+	// Make sure info does not contain new line
+	if strings.ContainsRune(info, '\n') {
+		info = ""
+	}
+	return &IpsetFormatter{
+		info: info,
+	}
+}
+
+func (f *IpsetFormatter) Marshal(rules []dto.Rule, w io.Writer) error {
+	now := time.Now()
+	_, err := fmt.Fprintf(w,
+		ipsetTemplatePrefix,
+		fmt.Sprintf(
+			`# Generated by %s
+# %s`,
+			f.info,
+			now.Format(time.RFC3339),
+		),
+		ipsetSetV4,
+		ipsetSetV6,
+	)
+	if err != nil {
+		return fmt.Errorf("write failed: %w", err)
+	}
+
+	for _, v := range collapseBanned(rules) {
+		timeout := ruleTimeout(v, now)
+		if timeout < 0 {
+			continue
+		}
+		// 0 is permanent
+		timeout = min(timeout, ipsetMaxTimeout)
+
+		set := ipsetSetV6
+		if v.Prefix.Addr().Is4() {
+			set = ipsetSetV4
+		}
+		_, err = fmt.Fprintf(w, ipsetTemplateEntry, set, v.Prefix.String(), timeout)
+		if err != nil {
+			return fmt.Errorf("write failed: %w", err)
+		}
+	}
+
+	_, err = fmt.Fprintf(w, ipsetTemplateSuffix, ipsetSetV4, ipsetSetV6)
+	if err != nil {
+		return fmt.Errorf("write failed: %w", err)
+	}
+
+	return nil
+}
+
+func (f *IpsetFormatter) Digest(rules []dto.Rule) []byte {
+	return digestBanned(rules)
+}
+
+func (f *IpsetFormatter) Expiry(rules []dto.Rule) time.Time {
+	return earliestBannedExpiry(rules)
+}
+
+func (f *IpsetFormatter) Info() string {
+	return f.info
+}
@@ -0,0 +1,75 @@
+package aclfmt
+
+import (
+	"bytes"
+	"net/netip"
+	"testing"
+	"time"
+
+	"github.com/HT4w5/nyaago/pkg/dto"
+)
+
+const (
+	ipsetTestPrefix = `create nyaago_banned_v4 hash:net family inet timeout 0 -exist
+create nyaago_banned_v6 hash:net family inet6 timeout 0 -exist
+create nyaago_banned_v4_tmp hash:net family inet timeout 0 -exist
+create nyaago_banned_v6_tmp hash:net family inet6 timeout 0 -exist
+flush nyaago_banned_v4_tmp
+flush nyaago_banned_v6_tmp
+`
+	ipsetTestSuffix = `swap nyaago_banned_v4_tmp nyaago_banned_v4
+swap nyaago_banned_v6_tmp nyaago_banned_v6
+destroy nyaago_banned_v4_tmp
+destroy nyaago_banned_v6_tmp
+`
+)
+
+func TestIpsetMarshal(t *testing.T) {
+	// Half a second of slack so timeouts round down to whole hours
+	now := time.Now().Add(500 * time.Millisecond)
+
+	tests := []struct {
+		name  string
+		rules []dto.Rule
+		want  string
+	}{
+		{
+			name: "empty",
+			want: ipsetTestPrefix + ipsetTestSuffix,
+		},
+		{
+			name: "ipv4 and ipv6 with timeouts",
+			rules: []dto.Rule{
+				{Prefix: netip.MustParsePrefix("192.0.2.0/24"), Banned: true, ExpiresAt: now.Add(time.Hour)},
+				{Prefix: netip.MustParsePrefix("203.0.113.7/32"), Banned: true},
+				{Prefix: netip.MustParsePrefix("2001:db8::/64"), Banned: true, ExpiresAt: now.Add(time.Hour)},
+				// Capped at the kernel limit
+				{Prefix: netip.MustParsePrefix("2001:db8:2::/48"), Banned: true, ExpiresAt: now.Add(1000 * time.Hour)},
+				// Covered, rate limited and expired rules are left out
+				{Prefix: netip.MustParsePrefix("192.0.2.128/25"), Banned: true},
+				{Prefix: netip.MustParsePrefix("198.51.100.0/24"), RateLimit: 1024},
+				{Prefix: netip.MustParsePrefix("2001:db8:1::/56"), Banned: true, ExpiresAt: now.Add(-time.Minute)},
+			},
+			// Broader prefixes first
+			want: ipsetTestPrefix + `add nyaago_banned_v4_tmp 192.0.2.0/24 timeout 3600 -exist
+add nyaago_banned_v4_tmp 203.0.113.7/32 timeout 0 -exist
+add nyaago_banned_v6_tmp 2001:db8:2::/48 timeout 2147483 -exist
+add nyaago_banned_v6_tmp 2001:db8::/64 timeout 3600 -exist
+` + ipsetTestSuffix,
+		},
+	}
+
+	f := makeIpsetFormatter("test")
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			err := f.Marshal(tt.rules, &buf)
+			if err != nil {
+				t.Fatalf("Marshal() error = %v", err)
+			}
+			if got := stripHeader(t, buf.String()); got != tt.want {
+				t.Errorf("Marshal() =\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
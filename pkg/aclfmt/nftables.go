@@ -0,0 +1,128 @@
+package aclfmt
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/HT4w5/nyaago/pkg/dto"
+)
+
+const (
+	nftTemplatePrefix = `%s
+table inet nyaago
+delete table inet nyaago
+table inet nyaago {
+`
+	nftTemplateSetPrefix = `    set %s {
+        type %s
+        flags interval, timeout
+`
+	nftTemplateSetSuffix = "    }\n"
+	nftTemplateSuffix    = `    chain prerouting {
+        type filter hook prerouting priority -150; policy accept;
+        ip saddr @banned_v4 drop
+        ip6 saddr @banned_v6 drop
+    }
+}
+`
+)
+
+// Emits an nft -f script replacing table inet nyaago.
+// Only banned rules are exported
+type NftablesFormatter struct {
+	info string
+}
+
+func makeNftablesFormatter(info string) *NftablesFormatter {
+	// Make sure info does not contain new line
+	if strings.ContainsRune(info, '\n') {
+		info = ""
+	}
+	return &NftablesFormatter{
+		info: info,
+	}
+}
+
+func (f *NftablesFormatter) Marshal(rules []dto.Rule, w io.Writer) error {
+	now := time.Now()
+	_, err := fmt.Fprintf(w,
+		nftTemplatePrefix,
+		fmt.Sprintf(
+			`# Generated by %s
+# %s`,
+			f.info,
+			now.Format(time.RFC3339),
+		))
+	if err != nil {
+		return fmt.Errorf("write failed: %w", err)
+	}
+
+	v4 := make([]string, 0)
+	v6 := make([]string, 0)
+	for _, v := range collapseBanned(rules) {
+		timeout := ruleTimeout(v, now)
+		if timeout < 0 {
+			continue
+		}
+		elem := v.Prefix.String()
+		if timeout > 0 {
+			elem = fmt.Sprintf("%s timeout %ds", elem, timeout)
+		}
+		if v.Prefix.Addr().Is4() {
+			v4 = append(v4, elem)
+		} else {
+			v6 = append(v6, elem)
+		}
+	}
+
+	err = writeNftSet(w, "banned_v4", "ipv4_addr", v4)
+	if err != nil {
+		return err
+	}
+	err = writeNftSet(w, "banned_v6", "ipv6_addr", v6)
+	if err != nil {
+		return err
+	}
+
+	_, err = fmt.Fprint(w, nftTemplateSuffix)
+	if err != nil {
+		return fmt.Errorf("write failed: %w", err)
+	}
+
+	return nil
+}
+
+func (f *NftablesFormatter) Digest(rules []dto.Rule) []byte {
+	return digestBanned(rules)
+}
+
+func (f *NftablesFormatter) Expiry(rules []dto.Rule) time.Time {
+	return earliestBannedExpiry(rules)
+}
+
+func (f *NftablesFormatter) Info() string {
+	return f.info
+}
+
+func writeNftSet(w io.Writer, name string, typ string, elems []string) error {
+	_, err := fmt.Fprintf(w, nftTemplateSetPrefix, name, typ)
+	if err != nil {
+		return fmt.Errorf("write failed: %w", err)
+	}
+
+	// nft rejects an empty element list
+	if len(elems) > 0 {
+		_, err = fmt.Fprintf(w, "        elements = {\n            %s\n        }\n", strings.Join(elems, ",\n            "))
+		if err != nil {
+			return fmt.Errorf("write failed: %w", err)
+		}
+	}
+
+	_, err = fmt.Fprint(w, nftTemplateSetSuffix)
+	if err != nil {
+		return fmt.Errorf("write failed: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,90 @@
+package aclfmt
+
+import (
+	"bytes"
+	"net/netip"
+	"testing"
+	"time"
+
+	"github.com/HT4w5/nyaago/pkg/dto"
+)
+
+const nftTestChain = `    chain prerouting {
+        type filter hook prerouting priority -150; policy accept;
+        ip saddr @banned_v4 drop
+        ip6 saddr @banned_v6 drop
+    }
+}
+`
+
+func TestNftablesMarshal(t *testing.T) {
+	// Half a second of slack so timeouts round down to whole hours
+	hour := time.Now().Add(time.Hour + 500*time.Millisecond)
+
+	tests := []struct {
+		name  string
+		rules []dto.Rule
+		want  string
+	}{
+		{
+			name: "empty",
+			want: `table inet nyaago
+delete table inet nyaago
+table inet nyaago {
+    set banned_v4 {
+        type ipv4_addr
+        flags interval, timeout
+    }
+    set banned_v6 {
+        type ipv6_addr
+        flags interval, timeout
+    }
+` + nftTestChain,
+		},
+		{
+			name: "ipv4 and ipv6 with timeouts",
+			rules: []dto.Rule{
+				{Prefix: netip.MustParsePrefix("192.0.2.0/24"), Banned: true, ExpiresAt: hour},
+				{Prefix: netip.MustParsePrefix("203.0.113.7/32"), Banned: true},
+				{Prefix: netip.MustParsePrefix("2001:db8::/64"), Banned: true, ExpiresAt: hour},
+				// Covered, rate limited and expired rules are left out
+				{Prefix: netip.MustParsePrefix("192.0.2.128/25"), Banned: true},
+				{Prefix: netip.MustParsePrefix("198.51.100.0/24"), RateLimit: 1024},
+				{Prefix: netip.MustParsePrefix("2001:db8:1::/48"), Banned: true, ExpiresAt: time.Now().Add(-time.Minute)},
+			},
+			want: `table inet nyaago
+delete table inet nyaago
+table inet nyaago {
+    set banned_v4 {
+        type ipv4_addr
+        flags interval, timeout
+        elements = {
+            192.0.2.0/24 timeout 3600s,
+            203.0.113.7/32
+        }
+    }
+    set banned_v6 {
+        type ipv6_addr
+        flags interval, timeout
+        elements = {
+            2001:db8::/64 timeout 3600s
+        }
+    }
+` + nftTestChain,
+		},
+	}
+
+	f := makeNftablesFormatter("test")
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			err := f.Marshal(tt.rules, &buf)
+			if err != nil {
+				t.Fatalf("Marshal() error = %v", err)
+			}
+			if got := stripHeader(t, buf.String()); got != tt.want {
+				t.Errorf("Marshal() =\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
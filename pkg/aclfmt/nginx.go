@@ -1,6 +1,7 @@
 package aclfmt
 
 import (
+	"crypto/sha256"
 	"fmt"
 	"io"
 	"strings"
@@ -10,14 +11,21 @@ import (
 )
 
 const (
-	nginxTemplatePrefix = `%s
-geo $nyaago_rate_limit {
+	nginxTemplatePrefix = "%s\n"
+	nginxTemplateRate   = `geo $nyaago_rate_limit {
     default 0;
 `
-	nginxTemplateSuffix = "}\n"
-	nginxTemplateEntry  = "    %s %d;\n" // prefix, ratelimit
+	nginxTemplateBanned = `}
+geo $nyaago_banned {
+    default 0;
+`
+	nginxTemplateSuffix      = "}\n"
+	nginxTemplateEntry       = "    %s %d;\n" // prefix, ratelimit
+	nginxTemplateBannedEntry = "    %s 1;\n"  // prefix
 )
 
+// Emits two geo blocks: $nyaago_rate_limit for limit_rate
+// and $nyaago_banned set to 1 for banned prefixes
 type NginxFormatter struct {
 	info string
 }
@@ -46,13 +54,53 @@ func (f *NginxFormatter) Marshal(rules []dto.Rule, w io.Writer) error {
 		return fmt.Errorf("write failed: %w", err)
 	}
 
+	return f.marshalBlocks(rules, w)
+}
+
+func (f *NginxFormatter) Digest(rules []dto.Rule) []byte {
+	h := sha256.New()
+	f.marshalBlocks(rules, h)
+	return h.Sum(nil)
+}
+
+// Nginx output carries no timeouts
+func (f *NginxFormatter) Expiry(rules []dto.Rule) time.Time {
+	return time.Time{}
+}
+
+// Write geo blocks without the header
+func (f *NginxFormatter) marshalBlocks(rules []dto.Rule, w io.Writer) error {
+	_, err := fmt.Fprint(w, nginxTemplateRate)
+	if err != nil {
+		return fmt.Errorf("write failed: %w", err)
+	}
+
+	// Banned rules carry no rate limit
 	for _, v := range rules {
+		if v.Banned {
+			continue
+		}
 		_, err = fmt.Fprintf(w, nginxTemplateEntry, v.Prefix.String(), v.RateLimit)
 		if err != nil {
 			return fmt.Errorf("write failed: %w", err)
 		}
 	}
 
+	_, err = fmt.Fprint(w, nginxTemplateBanned)
+	if err != nil {
+		return fmt.Errorf("write failed: %w", err)
+	}
+
+	for _, v := range rules {
+		if !v.Banned {
+			continue
+		}
+		_, err = fmt.Fprintf(w, nginxTemplateBannedEntry, v.Prefix.String())
+		if err != nil {
+			return fmt.Errorf("write failed: %w", err)
+		}
+	}
+
 	_, err = fmt.Fprint(w, nginxTemplateSuffix)
 	if err != nil {
 		return fmt.Errorf("write failed: %w", err)
@@ -0,0 +1,106 @@
+package aclfmt
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"net/netip"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/HT4w5/nyaago/pkg/dto"
+)
+
+// Strip the "# Generated by" and timestamp lines of formatter output
+func stripHeader(t *testing.T, out string) string {
+	t.Helper()
+	lines := strings.SplitN(out, "\n", 3)
+	if len(lines) != 3 || lines[0] != "# Generated by test" {
+		t.Fatalf("output without header: %q", out)
+	}
+	_, err := time.Parse("# "+time.RFC3339, lines[1])
+	if err != nil {
+		t.Fatalf("bad header timestamp: %v", err)
+	}
+	return lines[2]
+}
+
+func TestNginxMarshal(t *testing.T) {
+	tests := []struct {
+		name  string
+		rules []dto.Rule
+		want  string
+	}{
+		{
+			name: "empty",
+			want: `geo $nyaago_rate_limit {
+    default 0;
+}
+geo $nyaago_banned {
+    default 0;
+}
+`,
+		},
+		{
+			name: "rate limits and bans in separate blocks",
+			rules: []dto.Rule{
+				{Prefix: netip.MustParsePrefix("192.0.2.0/24"), Banned: true},
+				{Prefix: netip.MustParsePrefix("198.51.100.0/24"), RateLimit: 524288},
+				{Prefix: netip.MustParsePrefix("2001:db8::/64"), Banned: true},
+				{Prefix: netip.MustParsePrefix("2001:db8:1::/64"), RateLimit: 1024},
+			},
+			want: `geo $nyaago_rate_limit {
+    default 0;
+    198.51.100.0/24 524288;
+    2001:db8:1::/64 1024;
+}
+geo $nyaago_banned {
+    default 0;
+    192.0.2.0/24 1;
+    2001:db8::/64 1;
+}
+`,
+		},
+	}
+
+	f := makeNginxFormatter("test")
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			err := f.Marshal(tt.rules, &buf)
+			if err != nil {
+				t.Fatalf("Marshal() error = %v", err)
+			}
+			if got := stripHeader(t, buf.String()); got != tt.want {
+				t.Errorf("Marshal() =\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+// The digest covers the geo blocks only, so it holds across runs with different headers
+func TestNginxDigestIgnoresHeader(t *testing.T) {
+	rules := []dto.Rule{
+		{Prefix: netip.MustParsePrefix("192.0.2.0/24"), Banned: true},
+		{Prefix: netip.MustParsePrefix("198.51.100.0/24"), RateLimit: 1024},
+	}
+	f := makeNginxFormatter("test")
+
+	var buf bytes.Buffer
+	err := f.Marshal(rules, &buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body := sha256.Sum256([]byte(stripHeader(t, buf.String())))
+	if got := f.Digest(rules); !bytes.Equal(got, body[:]) {
+		t.Error("Digest() differs from the hash of the output without header")
+	}
+	if !bytes.Equal(f.Digest(rules), f.Digest(rules)) {
+		t.Error("Digest() differs between runs")
+	}
+
+	rules[1].RateLimit = 2048
+	if bytes.Equal(f.Digest(rules), body[:]) {
+		t.Error("Digest() unchanged with a different rate limit")
+	}
+}
@@ -0,0 +1,82 @@
+package aclfmt
+
+import (
+	"cmp"
+	"crypto/sha256"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/HT4w5/nyaago/pkg/dto"
+)
+
+// Get banned rules with prefixes covered by a broader banned prefix removed.
+// Firewall interval sets reject overlapping elements
+func collapseBanned(rules []dto.Rule) []dto.Rule {
+	banned := make([]dto.Rule, 0, len(rules))
+	for _, v := range rules {
+		if v.Banned {
+			v.Prefix = v.Prefix.Masked()
+			banned = append(banned, v)
+		}
+	}
+
+	// Broader prefixes first
+	slices.SortFunc(banned, func(a, b dto.Rule) int {
+		return cmp.Compare(a.Prefix.Bits(), b.Prefix.Bits())
+	})
+
+	res := make([]dto.Rule, 0, len(banned))
+	for _, v := range banned {
+		covered := false
+		for _, r := range res {
+			if r.Prefix.Bits() <= v.Prefix.Bits() && r.Prefix.Contains(v.Prefix.Addr()) {
+				covered = true
+				break
+			}
+		}
+		if !covered {
+			res = append(res, v)
+		}
+	}
+	return res
+}
+
+// Remaining lifetime of a rule in whole seconds.
+// Returns 0 for rules without expiry and -1 for expired rules
+func ruleTimeout(rule dto.Rule, now time.Time) int64 {
+	if rule.ExpiresAt.IsZero() {
+		return 0
+	}
+	d := rule.ExpiresAt.Sub(now)
+	if d < time.Second {
+		return -1
+	}
+	return int64(d / time.Second)
+}
+
+// Hash of banned prefixes.
+// Used by formatters whose output carries relative timeouts. Expiry is left out as
+// analyzers push it forward on every report; timeouts are refreshed before they
+// lapse using earliestBannedExpiry
+func digestBanned(rules []dto.Rule) []byte {
+	h := sha256.New()
+	for _, v := range collapseBanned(rules) {
+		fmt.Fprintln(h, v.Prefix.String())
+	}
+	return h.Sum(nil)
+}
+
+// Earliest expiry of banned prefixes, zero if none expires
+func earliestBannedExpiry(rules []dto.Rule) time.Time {
+	var res time.Time
+	for _, v := range collapseBanned(rules) {
+		if v.ExpiresAt.IsZero() {
+			continue
+		}
+		if res.IsZero() || v.ExpiresAt.Before(res) {
+			res = v.ExpiresAt
+		}
+	}
+	return res
+}
@@ -0,0 +1,95 @@
+package aclfmt
+
+import (
+	"bytes"
+	"net/netip"
+	"testing"
+	"time"
+
+	"github.com/HT4w5/nyaago/pkg/dto"
+)
+
+func TestCollapseBanned(t *testing.T) {
+	rules := []dto.Rule{
+		{Prefix: netip.MustParsePrefix("192.0.2.7/32"), Banned: true},
+		{Prefix: netip.MustParsePrefix("192.0.2.0/24"), Banned: true},
+		{Prefix: netip.MustParsePrefix("198.51.100.0/24"), Banned: false, RateLimit: 1024},
+		{Prefix: netip.MustParsePrefix("2001:db8::1/128"), Banned: true},
+		{Prefix: netip.MustParsePrefix("2001:db8:1::/48"), Banned: true},
+	}
+
+	got := collapseBanned(rules)
+	want := map[netip.Prefix]bool{
+		netip.MustParsePrefix("192.0.2.0/24"):    true,
+		netip.MustParsePrefix("2001:db8::1/128"): true,
+		netip.MustParsePrefix("2001:db8:1::/48"): true,
+	}
+
+	if len(got) != len(want) {
+		t.Fatalf("collapseBanned() returned %d rules, want %d", len(got), len(want))
+	}
+	for _, v := range got {
+		if !want[v.Prefix] {
+			t.Errorf("collapseBanned() returned unexpected prefix %s", v.Prefix)
+		}
+	}
+}
+
+func TestRuleTimeout(t *testing.T) {
+	now := time.Unix(1734345934, 0)
+	tests := []struct {
+		name      string
+		expiresAt time.Time
+		want      int64
+	}{
+		{
+			name:      "no expiry",
+			expiresAt: time.Time{},
+			want:      0,
+		},
+		{
+			name:      "expired",
+			expiresAt: now.Add(-time.Minute),
+			want:      -1,
+		},
+		{
+			name:      "less than a second left",
+			expiresAt: now.Add(500 * time.Millisecond),
+			want:      -1,
+		},
+		{
+			name:      "half an hour left",
+			expiresAt: now.Add(30*time.Minute + 200*time.Millisecond),
+			want:      1800,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := ruleTimeout(dto.Rule{ExpiresAt: tt.expiresAt}, now)
+			if got != tt.want {
+				t.Errorf("ruleTimeout() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDigestBannedIgnoresExpiry(t *testing.T) {
+	now := time.Unix(1734345934, 0)
+	rules := func(expiresAt time.Time, prefix string) []dto.Rule {
+		return []dto.Rule{
+			{Prefix: netip.MustParsePrefix(prefix), Banned: true, ExpiresAt: expiresAt},
+			{Prefix: netip.MustParsePrefix("198.51.100.0/24"), RateLimit: 1024, ExpiresAt: expiresAt},
+		}
+	}
+
+	a := digestBanned(rules(now.Add(time.Hour), "192.0.2.0/24"))
+	b := digestBanned(rules(now.Add(2*time.Hour), "192.0.2.0/24"))
+	if !bytes.Equal(a, b) {
+		t.Error("digestBanned() changed with expiry only")
+	}
+	c := digestBanned(rules(now.Add(time.Hour), "192.0.2.0/25"))
+	if bytes.Equal(a, c) {
+		t.Error("digestBanned() unchanged with different prefix")
+	}
+}
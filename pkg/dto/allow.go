@@ -0,0 +1,86 @@
+package dto
+
+import (
+	"bytes"
+	"encoding/gob"
+	"encoding/json"
+	"fmt"
+	"net/netip"
+	"time"
+)
+
+// Prefix that must never be limited
+type AllowEntry struct {
+	Prefix  netip.Prefix
+	Comment string
+	Static  bool // Seeded from config
+}
+
+func (e *AllowEntry) Marshal() ([]byte, error) {
+	var buf bytes.Buffer
+	enc := gob.NewEncoder(&buf)
+	if err := enc.Encode(e); err != nil {
+		return nil, fmt.Errorf("failed to encode entry: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+func (e *AllowEntry) Unmarshal(data []byte) error {
+	buf := bytes.NewReader(data)
+	dec := gob.NewDecoder(buf)
+	if err := dec.Decode(e); err != nil {
+		return fmt.Errorf("failed to decode entry: %w", err)
+	}
+	return nil
+}
+
+// Create fixed-length []byte key for an AllowEntry
+func (e AllowEntry) DBKey() []byte {
+	b := make([]byte, 17)
+	prefix := e.Prefix.Masked()
+	addr := prefix.Addr().As16()
+	copy(b[0:16], addr[:])
+	b[16] = uint8(prefix.Bits())
+	return b
+}
+
+func (e AllowEntry) MarshalJSON() ([]byte, error) {
+	return json.Marshal(AllowEntryJSON{
+		Prefix:  e.Prefix.String(),
+		Comment: e.Comment,
+		Static:  e.Static,
+	})
+}
+
+type AllowEntryJSON struct {
+	Prefix  string `json:"prefix"`
+	Comment string `json:"comment"`
+	Static  bool   `json:"static"`
+}
+
+// A rule suppressed by the allowlist
+type AllowHit struct {
+	Rule  Rule
+	Entry AllowEntry
+	Time  time.Time
+}
+
+func (h AllowHit) MarshalJSON() ([]byte, error) {
+	return json.Marshal(AllowHitJSON{
+		Rule:  h.Rule,
+		Entry: h.Entry,
+		Time:  h.Time.Format(time.RFC3339),
+	})
+}
+
+type AllowHitJSON struct {
+	Rule  Rule       `json:"rule"`
+	Entry AllowEntry `json:"entry"`
+	Time  string     `json:"time"`
+}
+
+// Request body for adding an allowlist entry through the API
+type PutAllowEntryJSON struct {
+	Prefix  string `json:"prefix"`
+	Comment string `json:"comment"`
+}
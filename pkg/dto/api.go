@@ -14,6 +14,61 @@ func MakeErrorJSON(err error) ErrorJSON {
 	}
 }
 
+type EgressJSON struct {
+	Name        string `json:"name"`
+	Format      string `json:"format"`
+	Path        string `json:"path"`
+	Shadow      bool   `json:"shadow"`
+	LastApplied string `json:"last_applied"` // Last time the file was written and post_exec ran
+	LastChanged string `json:"last_changed"` // Last time the rendered rule set changed
+	Digest      string `json:"digest"`
+}
+
+// Result of an HTTP ingress batch. Directive lines count as neither
+type IngressBatchJSON struct {
+	Accepted int `json:"accepted"`
+	Rejected int `json:"rejected"` // Lines that failed to parse
+	Dropped  int `json:"dropped"`  // Lines dropped by the buffer overflow policy. The rest of the batch is queued
+}
+
+type IngressStatsJSON struct {
+	Buffer  BufferStatsJSON          `json:"buffer"`
+	Sources []IngressSourceStatsJSON `json:"sources"`
+}
+
+// State of the buffer between ingress and analysis
+type BufferStatsJSON struct {
+	Workers  int    `json:"workers"`
+	Capacity int    `json:"capacity"`
+	Queued   int    `json:"queued"`
+	Overflow string `json:"overflow"`
+	Dropped  uint64 `json:"dropped"` // Requests refused or replaced on overflow. Tail sources retry refused requests
+}
+
+// Line counters of an ingress source. Skipped lines count as received only
+type IngressSourceStatsJSON struct {
+	Source      string `json:"source"`
+	Received    uint64 `json:"received"`
+	Parsed      uint64 `json:"parsed"`
+	ParseFailed uint64 `json:"parse_failed"`
+}
+
+// Log lines dropped from a sender not in a source's allowed_senders
+type IngressRejectionJSON struct {
+	Source   string `json:"source"`
+	Sender   string `json:"sender"`
+	Hostname string `json:"hostname"` // Hostname claimed in the last rejected message
+	Count    uint64 `json:"count"`
+	LastSeen string `json:"last_seen"`
+}
+
+// Result of changing a rule through the API
+type RuleChangeJSON struct {
+	Rule       Rule   `json:"rule"`
+	Applied    bool   `json:"applied"`               // Egress targets were written and post_exec ran
+	ApplyError string `json:"apply_error,omitempty"` // Why apply failed. The change is stored regardless
+}
+
 type PingJSON struct {
 	Msg string `json:"msg"`
 }
@@ -17,4 +17,6 @@ type Request struct {
 	Duration time.Duration
 	Host     string
 	Agent    string
+	Tag      string // Tag of the ingress source
+	Origin   string // Peer that sent the log line: verified TLS client name or address. Empty if local
 }
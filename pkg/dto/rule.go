@@ -11,12 +11,19 @@ import (
 	"github.com/docker/go-units"
 )
 
+const (
+	RuleSourceManual = "manual"
+)
+
 type Rule struct {
 	Prefix    netip.Prefix
 	Banned    bool
 	RateLimit int64
 	Blame     string
+	Source    string // Analyzer name, or RuleSourceManual
 	ExpiresAt time.Time
+	Manual    bool // Created through the API. Analyzers never override manual rules
+	Shadow    bool // Created by an analyzer in shadow mode. Stored and exported separately
 }
 
 func (e *Rule) Marshal() ([]byte, error) {
@@ -53,7 +60,10 @@ func (r Rule) MarshalJSON() ([]byte, error) {
 		Banned:    r.Banned,
 		RateLimit: units.HumanSize(float64(r.RateLimit)),
 		Blame:     r.Blame,
+		Source:    r.Source,
 		ExpiresAt: r.ExpiresAt.Format(time.RFC3339),
+		Manual:    r.Manual,
+		Shadow:    r.Shadow,
 	})
 }
 
@@ -62,5 +72,17 @@ type RuleJSON struct {
 	Banned    bool   `json:"banned"`
 	RateLimit string `json:"rate_limit"`
 	Blame     string `json:"blame"`
+	Source    string `json:"source"`
 	ExpiresAt string `json:"expires_at"`
+	Manual    bool   `json:"manual"`
+	Shadow    bool   `json:"shadow"`
+}
+
+// Request body for creating or overriding a rule through the API
+type PutRuleJSON struct {
+	Prefix    string `json:"prefix"` // Optional if given in the URL
+	Banned    bool   `json:"banned"`
+	RateLimit string `json:"rate_limit"` // Human readable size per second, e.g. "512KB". Empty for no limit
+	Blame     string `json:"blame"`
+	TTL       string `json:"ttl"` // Go duration string. Empty for ip_list.entry_ttl
 }
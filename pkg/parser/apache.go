@@ -0,0 +1,61 @@
+package parser
+
+import (
+	"fmt"
+	"net/netip"
+	"strconv"
+	"time"
+
+	"github.com/HT4w5/nyaago/pkg/dto"
+)
+
+const apacheCombinedFields = 9
+
+// Parser for Apache httpd's combined log format with optional request duration:
+//
+//	%h %l %u %t "%r" %>s %b "%{Referer}i" "%{User-agent}i" %D
+//
+// %h must be an address, i.e. HostnameLookups off. %D is in microseconds
+type ApacheCombinedParser struct{}
+
+func (p *ApacheCombinedParser) Parse(line []byte) (dto.Request, error) {
+	fields, err := splitFields(string(line))
+	if err != nil {
+		return dto.Request{}, err
+	}
+	if len(fields) < apacheCombinedFields {
+		return dto.Request{}, fmt.Errorf("expected at least %d fields, got %d", apacheCombinedFields, len(fields))
+	}
+
+	var r dto.Request
+	r.Client, err = netip.ParseAddr(fields[0])
+	if err != nil {
+		return dto.Request{}, err
+	}
+	r.Time, err = time.Parse(nginxTimeLocalLayout, fields[3])
+	if err != nil {
+		return dto.Request{}, err
+	}
+	r.Method, r.URL = splitRequestLine(fields[4])
+	r.Status, err = strconv.Atoi(fields[5])
+	if err != nil {
+		return dto.Request{}, fmt.Errorf("bad status: %w", err)
+	}
+	// %b logs "-" instead of 0
+	if fields[6] != "-" {
+		r.Sent, err = strconv.ParseInt(fields[6], 10, 64)
+		if err != nil {
+			return dto.Request{}, fmt.Errorf("bad response size: %w", err)
+		}
+	}
+	r.Agent = fields[8]
+
+	if len(fields) > apacheCombinedFields {
+		r.Duration, err = parseDurationLayout(fields[9], DurationLayoutUs)
+		if err != nil {
+			return dto.Request{}, fmt.Errorf("bad request duration: %w", err)
+		}
+	}
+
+	return r, nil
+}
@@ -0,0 +1,78 @@
+package parser
+
+import (
+	"net/netip"
+	"testing"
+	"time"
+
+	"github.com/HT4w5/nyaago/pkg/dto"
+)
+
+func TestApacheCombinedParse(t *testing.T) {
+	reqTime := time.Date(2025, time.December, 16, 18, 25, 34, 0, time.FixedZone("", 8*3600))
+
+	tests := []struct {
+		name    string
+		input   string
+		want    dto.Request
+		wantErr bool
+	}{
+		{
+			name:  "combined with duration",
+			input: `192.0.2.10 - - [16/Dec/2025:18:25:34 +0800] "GET /downloads/debian.iso?mirror=1 HTTP/1.1" 200 4096 "https://example.com/" "Wget/1.21.4" 2534`,
+			want: dto.Request{
+				Time:     reqTime,
+				Client:   netip.MustParseAddr("192.0.2.10"),
+				Method:   "GET",
+				URL:      "/downloads/debian.iso",
+				Status:   200,
+				Sent:     4096,
+				Duration: 2534 * time.Microsecond,
+				Agent:    "Wget/1.21.4",
+			},
+		},
+		{
+			name:  "combined without duration and empty body",
+			input: `2001:db8::1 - alice [16/Dec/2025:18:25:34 +0800] "HEAD / HTTP/1.1" 304 - "-" "curl/8.5.0"`,
+			want: dto.Request{
+				Time:   reqTime,
+				Client: netip.MustParseAddr("2001:db8::1"),
+				Method: "HEAD",
+				URL:    "/",
+				Status: 304,
+				Agent:  "curl/8.5.0",
+			},
+		},
+		{
+			name:    "hostname lookups enabled",
+			input:   `client.example.com - - [16/Dec/2025:18:25:34 +0800] "GET / HTTP/1.1" 200 10 "-" "-"`,
+			wantErr: true,
+		},
+		{
+			name:    "truncated",
+			input:   `192.0.2.10 - - [16/Dec/2025:18:25:34 +0800] "GET / HTTP/1.1" 200`,
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := &ApacheCombinedParser{}
+			got, err := p.Parse([]byte(tt.input))
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Parse() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if tt.wantErr {
+				return
+			}
+			if !got.Time.Equal(tt.want.Time) {
+				t.Errorf("Parse() time = %v, want %v", got.Time, tt.want.Time)
+			}
+			got.Time = tt.want.Time
+			if got != tt.want {
+				t.Errorf("Parse() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,81 @@
+package parser
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/netip"
+	"strings"
+
+	"github.com/HT4w5/nyaago/pkg/dto"
+)
+
+type caddyLogEntry struct {
+	Ts      json.Number `json:"ts"` // Unix seconds
+	Request struct {
+		RemoteIP string              `json:"remote_ip"`
+		ClientIP string              `json:"client_ip"` // Set when trusted_proxies is configured
+		Method   string              `json:"method"`
+		Host     string              `json:"host"`
+		URI      string              `json:"uri"`
+		Headers  map[string][]string `json:"headers"`
+	} `json:"request"`
+	Duration json.Number `json:"duration"` // Seconds
+	Size     int64       `json:"size"`
+	Status   int         `json:"status"`
+}
+
+// Parser for Caddy's structured JSON access log.
+// client_ip is preferred; with ProxyOptions the forwarding headers of remote_ip are walked instead
+type CaddyParser struct {
+	proxy clientResolver
+}
+
+func (p *CaddyParser) Parse(line []byte) (dto.Request, error) {
+	var logEntry caddyLogEntry
+	err := json.Unmarshal(line, &logEntry)
+	if err != nil {
+		return dto.Request{}, err
+	}
+
+	r := dto.Request{
+		Method: logEntry.Request.Method,
+		URL:    stripQuery(logEntry.Request.URI),
+		Status: logEntry.Status,
+		Sent:   logEntry.Size,
+		Host:   logEntry.Request.Host,
+	}
+	r.Agent = firstHeader(logEntry.Request.Headers["User-Agent"])
+
+	headers := logEntry.Request.Headers
+	client := logEntry.Request.ClientIP
+	if client == "" || len(p.proxy.trusted) > 0 {
+		client = logEntry.Request.RemoteIP
+	}
+	r.Client, err = netip.ParseAddr(client)
+	if err != nil {
+		return dto.Request{}, err
+	}
+	r.Client = p.proxy.resolve(r.Client, forwarded{
+		ForwardedFor: strings.Join(headers["X-Forwarded-For"], ","),
+		RealIP:       firstHeader(headers["X-Real-Ip"]),
+	})
+	r.Time, err = parseUnix(logEntry.Ts.String(), nsPerS)
+	if err != nil {
+		return dto.Request{}, fmt.Errorf("bad ts: %w", err)
+	}
+	if logEntry.Duration != "" {
+		r.Duration, err = parseDurationLayout(logEntry.Duration.String(), DurationLayoutSeconds)
+		if err != nil {
+			return dto.Request{}, fmt.Errorf("bad duration: %w", err)
+		}
+	}
+
+	return r, nil
+}
+
+func firstHeader(values []string) string {
+	if len(values) == 0 {
+		return ""
+	}
+	return values[0]
+}
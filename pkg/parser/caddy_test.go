@@ -0,0 +1,73 @@
+package parser
+
+import (
+	"net/netip"
+	"testing"
+	"time"
+
+	"github.com/HT4w5/nyaago/pkg/dto"
+)
+
+func TestCaddyParse(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		want    dto.Request
+		wantErr bool
+	}{
+		{
+			name:  "access log",
+			input: `{"level":"info","ts":1734345934.123,"logger":"http.log.access.log0","msg":"handled request","request":{"remote_ip":"192.0.2.10","remote_port":"41342","client_ip":"192.0.2.10","proto":"HTTP/2.0","method":"GET","host":"example.com","uri":"/downloads/debian.iso?mirror=1","headers":{"User-Agent":["curl/8.5.0"],"Accept":["*/*"]}},"bytes_read":0,"user_id":"","duration":0.000929675,"size":10900,"status":200,"resp_headers":{"Server":["Caddy"]}}`,
+			want: dto.Request{
+				Time:     time.Unix(1734345934, 123000000),
+				Client:   netip.MustParseAddr("192.0.2.10"),
+				Method:   "GET",
+				URL:      "/downloads/debian.iso",
+				Status:   200,
+				Sent:     10900,
+				Duration: 929675 * time.Nanosecond,
+				Host:     "example.com",
+				Agent:    "curl/8.5.0",
+			},
+		},
+		{
+			name:  "client ip behind trusted proxy",
+			input: `{"ts":1734345934,"request":{"remote_ip":"10.0.0.2","client_ip":"2001:db8::1","method":"POST","host":"example.com","uri":"/api","headers":{}},"duration":1.5,"size":0,"status":204}`,
+			want: dto.Request{
+				Time:     time.Unix(1734345934, 0),
+				Client:   netip.MustParseAddr("2001:db8::1"),
+				Method:   "POST",
+				URL:      "/api",
+				Status:   204,
+				Duration: 1500 * time.Millisecond,
+				Host:     "example.com",
+			},
+		},
+		{
+			name:    "missing remote ip",
+			input:   `{"ts":1734345934,"request":{"method":"GET"},"status":200}`,
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := &CaddyParser{}
+			got, err := p.Parse([]byte(tt.input))
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Parse() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if tt.wantErr {
+				return
+			}
+			if !got.Time.Equal(tt.want.Time) {
+				t.Errorf("Parse() time = %v, want %v", got.Time, tt.want.Time)
+			}
+			got.Time = tt.want.Time
+			if got != tt.want {
+				t.Errorf("Parse() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
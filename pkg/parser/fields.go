@@ -0,0 +1,74 @@
+package parser
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// Split a text access log line into space separated fields.
+// Fields quoted with "" or bracketed with [] or {} may contain spaces and are returned without delimiters.
+// Backslash escapes inside quotes are kept verbatim
+func splitFields(line string) ([]string, error) {
+	fields := make([]string, 0, 16)
+	i := 0
+	for i < len(line) {
+		switch line[i] {
+		case ' ', '\t':
+			i++
+		case '"':
+			end := i + 1
+			for end < len(line) && line[end] != '"' {
+				if line[end] == '\\' {
+					end++
+				}
+				end++
+			}
+			if end >= len(line) {
+				return nil, fmt.Errorf("unterminated quote at %d", i)
+			}
+			fields = append(fields, line[i+1:end])
+			i = end + 1
+		case '[', '{':
+			closing := byte(']')
+			if line[i] == '{' {
+				closing = '}'
+			}
+			end := strings.IndexByte(line[i:], closing)
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated bracket at %d", i)
+			}
+			fields = append(fields, line[i+1:i+end])
+			i += end + 1
+		default:
+			end := strings.IndexAny(line[i:], " \t")
+			if end < 0 {
+				end = len(line) - i
+			}
+			fields = append(fields, line[i:i+end])
+			i += end
+		}
+	}
+	return fields, nil
+}
+
+// Split a request line like "GET /path?query HTTP/1.1" into method and path.
+// Malformed request lines yield empty fields
+func splitRequestLine(request string) (method string, path string) {
+	parts := strings.Fields(request)
+	if len(parts) < 2 {
+		return "", ""
+	}
+	return parts[0], stripQuery(parts[1])
+}
+
+// Drop query string to match nginx's $uri
+func stripQuery(uri string) string {
+	if idx := strings.IndexByte(uri, '?'); idx >= 0 {
+		uri = uri[:idx]
+	}
+	if unescaped, err := url.PathUnescape(uri); err == nil {
+		uri = unescaped
+	}
+	return uri
+}
@@ -0,0 +1,92 @@
+package parser
+
+import (
+	"fmt"
+	"net/netip"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/HT4w5/nyaago/pkg/dto"
+)
+
+const (
+	haproxyDateLayout = "02/Jan/2006:15:04:05.000"
+
+	// Fields after client address up to and including bytes_read
+	haproxyMinFields = 7
+)
+
+// Parser for HAProxy's option httplog format:
+//
+//	client_ip:port [accept_date] frontend backend/server TR/Tw/Tc/Tr/Ta status bytes_read ... "request"
+//
+// A leading syslog header is skipped. Accept dates carry no zone and are taken as local time
+type HAProxyParser struct{}
+
+func (p *HAProxyParser) Parse(line []byte) (dto.Request, error) {
+	fields, err := splitFields(string(line))
+	if err != nil {
+		return dto.Request{}, err
+	}
+
+	// Skip syslog header by locating client address followed by accept date
+	start := -1
+	var r dto.Request
+	for i := 0; i+1 < len(fields); i++ {
+		client, ok := parseHAProxyClient(fields[i])
+		if !ok {
+			continue
+		}
+		r.Time, err = time.ParseInLocation(haproxyDateLayout, fields[i+1], time.Local)
+		if err != nil {
+			continue
+		}
+		r.Client = client
+		start = i
+		break
+	}
+	if start < 0 {
+		return dto.Request{}, fmt.Errorf("client address and accept date not found")
+	}
+	fields = fields[start:]
+	if len(fields) < haproxyMinFields+1 {
+		return dto.Request{}, fmt.Errorf("expected at least %d fields, got %d", haproxyMinFields+1, len(fields))
+	}
+
+	// Total active time is the last timer. -1 for aborted requests
+	timers := strings.Split(fields[4], "/")
+	if total, err := strconv.ParseInt(strings.TrimPrefix(timers[len(timers)-1], "+"), 10, 64); err == nil && total > 0 {
+		r.Duration = time.Duration(total) * time.Millisecond
+	}
+
+	r.Status, err = strconv.Atoi(fields[5])
+	if err != nil {
+		return dto.Request{}, fmt.Errorf("bad status: %w", err)
+	}
+	r.Sent, err = strconv.ParseInt(strings.TrimPrefix(fields[6], "+"), 10, 64)
+	if err != nil {
+		return dto.Request{}, fmt.Errorf("bad bytes read: %w", err)
+	}
+
+	// Request line is always last
+	r.Method, r.URL = splitRequestLine(fields[len(fields)-1])
+
+	return r, nil
+}
+
+// Parse client_ip:port. IPv6 addresses are logged without brackets
+func parseHAProxyClient(s string) (netip.Addr, bool) {
+	idx := strings.LastIndexByte(s, ':')
+	if idx < 0 {
+		return netip.Addr{}, false
+	}
+	if _, err := strconv.ParseUint(s[idx+1:], 10, 16); err != nil {
+		return netip.Addr{}, false
+	}
+	addr, err := netip.ParseAddr(s[:idx])
+	if err != nil {
+		return netip.Addr{}, false
+	}
+	return addr.Unmap(), true
+}
@@ -0,0 +1,88 @@
+package parser
+
+import (
+	"net/netip"
+	"testing"
+	"time"
+
+	"github.com/HT4w5/nyaago/pkg/dto"
+)
+
+func TestHAProxyParse(t *testing.T) {
+	reqTime := time.Date(2009, time.February, 6, 12, 14, 14, 655000000, time.Local)
+
+	tests := []struct {
+		name    string
+		input   string
+		want    dto.Request
+		wantErr bool
+	}{
+		{
+			name:  "with syslog header",
+			input: `Feb  6 12:14:14 localhost haproxy[14389]: 10.0.1.2:33317 [06/Feb/2009:12:14:14.655] http-in static/srv1 10/0/30/69/109 200 2750 - - ---- 1/1/1/1/0 0/0 {1wt.eu} {} "GET /index.html?lang=en HTTP/1.1"`,
+			want: dto.Request{
+				Time:     reqTime,
+				Client:   netip.MustParseAddr("10.0.1.2"),
+				Method:   "GET",
+				URL:      "/index.html",
+				Status:   200,
+				Sent:     2750,
+				Duration: 109 * time.Millisecond,
+			},
+		},
+		{
+			name:  "ipv6 client and captured header with spaces",
+			input: `2001:db8::1:33317 [06/Feb/2009:12:14:14.655] http-in~ static/srv1 0/0/1/2/+3 304 +150 - - ---- 1/1/1/1/0 0/0 {Mozilla/5.0 (X11; Linux x86_64)} "HEAD / HTTP/2.0"`,
+			want: dto.Request{
+				Time:     reqTime,
+				Client:   netip.MustParseAddr("2001:db8::1"),
+				Method:   "HEAD",
+				URL:      "/",
+				Status:   304,
+				Sent:     150,
+				Duration: 3 * time.Millisecond,
+			},
+		},
+		{
+			name:  "aborted request",
+			input: `10.0.1.2:33318 [06/Feb/2009:12:14:14.655] http-in http-in/<NOSRV> -1/-1/-1/-1/-1 400 187 - - PR-- 1/1/0/0/0 0/0 "<BADREQ>"`,
+			want: dto.Request{
+				Time:   reqTime,
+				Client: netip.MustParseAddr("10.0.1.2"),
+				Status: 400,
+				Sent:   187,
+			},
+		},
+		{
+			name:    "no client address",
+			input:   `Feb  6 12:14:14 localhost haproxy[14389]: Proxy http-in started.`,
+			wantErr: true,
+		},
+		{
+			name:    "truncated",
+			input:   `10.0.1.2:33317 [06/Feb/2009:12:14:14.655] http-in static/srv1`,
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := &HAProxyParser{}
+			got, err := p.Parse([]byte(tt.input))
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Parse() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if tt.wantErr {
+				return
+			}
+			if !got.Time.Equal(tt.want.Time) {
+				t.Errorf("Parse() time = %v, want %v", got.Time, tt.want.Time)
+			}
+			got.Time = tt.want.Time
+			if got != tt.want {
+				t.Errorf("Parse() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,223 @@
+package parser
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/netip"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/HT4w5/nyaago/pkg/dto"
+)
+
+const (
+	TimeLayoutAuto = "auto" // Numbers as unix seconds or milliseconds, strings as RFC 3339 or numbers
+
+	unixMsThreshold = 1e11 // Larger timestamps are taken as milliseconds
+)
+
+// JSON paths of dto.Request fields. Nested keys are separated by dots.
+// Empty paths default to the field name, e.g. "client"
+type JSONFields struct {
+	Client   string
+	Time     string
+	Server   string
+	Method   string
+	URL      string
+	Status   string
+	Sent     string
+	Duration string
+	Host     string
+	Agent    string
+
+	// Forwarding fields, see ProxyOptions. Unset paths are not looked up
+	ForwardedFor  string
+	RealIP        string
+	ProxyProtocol string
+}
+
+type JSONOptions struct {
+	Fields         JSONFields
+	TimeLayout     string // See parseTimeLayout, or auto. Defaults to auto
+	DurationLayout string // See parseDurationLayout. Defaults to seconds
+}
+
+// Parser for arbitrary JSON logs with configurable field paths.
+// Numeric fields may be JSON numbers or strings. Missing optional fields are left at zero value
+type JSONParser struct {
+	opts  JSONOptions
+	paths map[string][]string // Field name to split path
+	proxy clientResolver
+}
+
+func MakeJSONParser(opts JSONOptions, proxy ProxyOptions) (*JSONParser, error) {
+	if opts.TimeLayout != TimeLayoutAuto {
+		err := validateTimeLayout(opts.TimeLayout)
+		if err != nil {
+			return nil, err
+		}
+	}
+	err := validateDurationLayout(opts.DurationLayout)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &JSONParser{
+		opts:  opts,
+		paths: make(map[string][]string),
+		proxy: makeClientResolver(proxy),
+	}
+	f := &opts.Fields
+	for _, v := range []struct {
+		name string
+		path string
+	}{
+		{"client", f.Client},
+		{"time", f.Time},
+		{"server", f.Server},
+		{"method", f.Method},
+		{"url", f.URL},
+		{"status", f.Status},
+		{"sent", f.Sent},
+		{"duration", f.Duration},
+		{"host", f.Host},
+		{"agent", f.Agent},
+	} {
+		path := v.path
+		if path == "" {
+			path = v.name
+		}
+		p.paths[v.name] = strings.Split(path, ".")
+	}
+	for _, v := range []struct {
+		name string
+		path string
+	}{
+		{"forwarded_for", f.ForwardedFor},
+		{"real_ip", f.RealIP},
+		{"proxy_protocol", f.ProxyProtocol},
+	} {
+		if v.path != "" {
+			p.paths[v.name] = strings.Split(v.path, ".")
+		}
+	}
+
+	return p, nil
+}
+
+func (p *JSONParser) Parse(line []byte) (dto.Request, error) {
+	dec := json.NewDecoder(bytes.NewReader(line))
+	dec.UseNumber()
+	var obj map[string]any
+	err := dec.Decode(&obj)
+	if err != nil {
+		return dto.Request{}, err
+	}
+
+	field := func(name string) (string, bool) {
+		return lookupJSONPath(obj, p.paths[name])
+	}
+
+	var r dto.Request
+	s, ok := field("client")
+	if !ok {
+		return dto.Request{}, fmt.Errorf("client field missing")
+	}
+	r.Client, err = netip.ParseAddr(s)
+	if err != nil {
+		return dto.Request{}, err
+	}
+	var fwd forwarded
+	fwd.ForwardedFor, _ = field("forwarded_for")
+	fwd.RealIP, _ = field("real_ip")
+	fwd.ProxyProtocol, _ = field("proxy_protocol")
+	r.Client = p.proxy.resolve(r.Client, fwd)
+	if s, ok := field("server"); ok && s != "" {
+		r.Server, err = netip.ParseAddr(s)
+		if err != nil {
+			return dto.Request{}, err
+		}
+	}
+	if s, ok := field("time"); ok {
+		r.Time, err = p.parseTime(s)
+		if err != nil {
+			return dto.Request{}, fmt.Errorf("bad time: %w", err)
+		}
+	}
+	r.Method, _ = field("method")
+	if s, ok := field("url"); ok {
+		r.URL = stripQuery(s)
+	}
+	if s, ok := field("status"); ok {
+		r.Status, err = strconv.Atoi(s)
+		if err != nil {
+			return dto.Request{}, fmt.Errorf("bad status: %w", err)
+		}
+	}
+	if s, ok := field("sent"); ok && s != "-" {
+		r.Sent, err = strconv.ParseInt(s, 10, 64)
+		if err != nil {
+			return dto.Request{}, fmt.Errorf("bad sent: %w", err)
+		}
+	}
+	if s, ok := field("duration"); ok && s != "-" {
+		r.Duration, err = parseDurationLayout(s, p.opts.DurationLayout)
+		if err != nil {
+			return dto.Request{}, fmt.Errorf("bad duration: %w", err)
+		}
+	}
+	r.Host, _ = field("host")
+	r.Agent, _ = field("agent")
+
+	return r, nil
+}
+
+func (p *JSONParser) parseTime(s string) (time.Time, error) {
+	if p.opts.TimeLayout != TimeLayoutAuto && p.opts.TimeLayout != "" {
+		return parseTimeLayout(s, p.opts.TimeLayout)
+	}
+
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return time.Parse(time.RFC3339Nano, s)
+	}
+	if f > unixMsThreshold {
+		return parseUnix(s, nsPerS/1000)
+	}
+	return parseUnix(s, nsPerS)
+}
+
+// Get a scalar at path as string. Returns false if missing or null
+func lookupJSONPath(obj map[string]any, path []string) (string, bool) {
+	var v any = obj
+	for _, k := range path {
+		m, ok := v.(map[string]any)
+		if !ok {
+			return "", false
+		}
+		v, ok = m[k]
+		if !ok {
+			return "", false
+		}
+	}
+
+	switch t := v.(type) {
+	case string:
+		return t, true
+	case json.Number:
+		return t.String(), true
+	case bool:
+		return strconv.FormatBool(t), true
+	case []any:
+		// Header values in some formats are arrays, e.g. Caddy's request.headers
+		if len(t) == 0 {
+			return "", false
+		}
+		s, ok := t[0].(string)
+		return s, ok
+	default:
+		return "", false
+	}
+}
@@ -0,0 +1,115 @@
+package parser
+
+import (
+	"net/netip"
+	"testing"
+	"time"
+
+	"github.com/HT4w5/nyaago/pkg/dto"
+)
+
+func TestJSONParse(t *testing.T) {
+	tests := []struct {
+		name    string
+		opts    JSONOptions
+		input   string
+		want    dto.Request
+		wantErr bool
+	}{
+		{
+			name: "configs/nginx/example.json",
+			opts: JSONOptions{
+				Fields: JSONFields{
+					Client:   "remote_addr",
+					Time:     "timestamp",
+					Method:   "request_method",
+					URL:      "request_uri",
+					Duration: "request_time",
+					Sent:     "body_bytes_sent",
+				},
+			},
+			input: `{"timestamp": 1765912302.327, "remote_addr": "172.20.0.1", "request_method": "GET", "request_uri": "/?a=b", "status": "304", "request_time": 0.002, "body_bytes_sent": 0, "http_x_forwarded_for": ""}`,
+			want: dto.Request{
+				Time:     time.Unix(1765912302, 327000000),
+				Client:   netip.MustParseAddr("172.20.0.1"),
+				Method:   "GET",
+				URL:      "/",
+				Status:   304,
+				Duration: 2 * time.Millisecond,
+			},
+		},
+		{
+			name: "nested paths and unix ms",
+			opts: JSONOptions{
+				Fields: JSONFields{
+					Client: "request.remote_ip",
+					Time:   "ts",
+					Host:   "request.host",
+				},
+			},
+			input: `{"ts": 1765912302327, "request": {"remote_ip": "2001:db8::1", "host": "example.com"}, "status": 200}`,
+			want: dto.Request{
+				Time:   time.Unix(1765912302, 327000000),
+				Client: netip.MustParseAddr("2001:db8::1"),
+				Host:   "example.com",
+				Status: 200,
+			},
+		},
+		{
+			name: "rfc3339 string time and go duration",
+			opts: JSONOptions{
+				TimeLayout:     TimeLayoutRFC3339,
+				DurationLayout: DurationLayoutGo,
+			},
+			input: `{"time": "2025-12-16T19:11:42.5Z", "client": "192.0.2.10", "duration": "1.5ms", "sent": "42"}`,
+			want: dto.Request{
+				Time:     time.Date(2025, time.December, 16, 19, 11, 42, 500000000, time.UTC),
+				Client:   netip.MustParseAddr("192.0.2.10"),
+				Sent:     42,
+				Duration: 1500 * time.Microsecond,
+			},
+		},
+		{
+			name:    "missing client",
+			opts:    JSONOptions{},
+			input:   `{"time": 1765912302}`,
+			wantErr: true,
+		},
+		{
+			name:    "client not a scalar",
+			opts:    JSONOptions{},
+			input:   `{"client": {"ip": "192.0.2.10"}}`,
+			wantErr: true,
+		},
+		{
+			name:    "invalid json",
+			opts:    JSONOptions{},
+			input:   `{"client": "192.0.2.10"`,
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := MakeJSONParser(tt.opts, ProxyOptions{})
+			if err != nil {
+				t.Fatalf("MakeJSONParser() error = %v", err)
+			}
+			got, err := p.Parse([]byte(tt.input))
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Parse() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if tt.wantErr {
+				return
+			}
+			if !got.Time.Equal(tt.want.Time) {
+				t.Errorf("Parse() time = %v, want %v", got.Time, tt.want.Time)
+			}
+			got.Time = tt.want.Time
+			if got != tt.want {
+				t.Errorf("Parse() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
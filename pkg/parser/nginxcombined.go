@@ -0,0 +1,71 @@
+package parser
+
+import (
+	"fmt"
+	"net/netip"
+	"strconv"
+	"time"
+
+	"github.com/HT4w5/nyaago/pkg/dto"
+)
+
+const (
+	nginxTimeLocalLayout = "02/Jan/2006:15:04:05 -0700"
+
+	nginxCombinedFields         = 9
+	nginxCombinedExtendedFields = 11
+)
+
+// Parser for nginx's stock combined log format:
+//
+//	$remote_addr - $remote_user [$time_local] "$request" $status $body_bytes_sent "$http_referer" "$http_user_agent"
+//
+// The extended variant expects $request_time $host appended
+type NginxCombinedParser struct {
+	extended bool
+}
+
+func (p *NginxCombinedParser) Parse(line []byte) (dto.Request, error) {
+	fields, err := splitFields(string(line))
+	if err != nil {
+		return dto.Request{}, err
+	}
+
+	want := nginxCombinedFields
+	if p.extended {
+		want = nginxCombinedExtendedFields
+	}
+	if len(fields) < want {
+		return dto.Request{}, fmt.Errorf("expected %d fields, got %d", want, len(fields))
+	}
+
+	var r dto.Request
+	r.Client, err = netip.ParseAddr(fields[0])
+	if err != nil {
+		return dto.Request{}, err
+	}
+	r.Time, err = time.Parse(nginxTimeLocalLayout, fields[3])
+	if err != nil {
+		return dto.Request{}, err
+	}
+	r.Method, r.URL = splitRequestLine(fields[4])
+	r.Status, err = strconv.Atoi(fields[5])
+	if err != nil {
+		return dto.Request{}, fmt.Errorf("bad status: %w", err)
+	}
+	r.Sent, err = strconv.ParseInt(fields[6], 10, 64)
+	if err != nil {
+		return dto.Request{}, fmt.Errorf("bad body bytes sent: %w", err)
+	}
+	r.Agent = fields[8]
+
+	if p.extended {
+		r.Duration, err = parseNginxDuration(fields[9])
+		if err != nil {
+			return dto.Request{}, fmt.Errorf("bad request time: %w", err)
+		}
+		r.Host = fields[10]
+	}
+
+	return r, nil
+}
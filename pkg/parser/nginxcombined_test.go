@@ -0,0 +1,112 @@
+package parser
+
+import (
+	"net/netip"
+	"testing"
+	"time"
+
+	"github.com/HT4w5/nyaago/pkg/dto"
+)
+
+func TestNginxCombinedParse(t *testing.T) {
+	reqTime := time.Date(2025, time.December, 16, 18, 25, 34, 0, time.FixedZone("", 8*3600))
+
+	tests := []struct {
+		name     string
+		extended bool
+		input    string
+		want     dto.Request
+		wantErr  bool
+	}{
+		{
+			name:  "combined",
+			input: `192.0.2.10 - - [16/Dec/2025:18:25:34 +0800] "GET /downloads/debian.iso?mirror=1 HTTP/1.1" 200 4096 "https://example.com/" "Wget/1.21.4"`,
+			want: dto.Request{
+				Time:   reqTime,
+				Client: netip.MustParseAddr("192.0.2.10"),
+				Method: "GET",
+				URL:    "/downloads/debian.iso",
+				Status: 200,
+				Sent:   4096,
+				Agent:  "Wget/1.21.4",
+			},
+		},
+		{
+			name:  "combined with ipv6 client and user",
+			input: `2001:db8::1 - alice [16/Dec/2025:18:25:34 +0800] "HEAD /a%20b HTTP/2.0" 304 0 "-" "curl/8.5.0"`,
+			want: dto.Request{
+				Time:   reqTime,
+				Client: netip.MustParseAddr("2001:db8::1"),
+				Method: "HEAD",
+				URL:    "/a b",
+				Status: 304,
+				Sent:   0,
+				Agent:  "curl/8.5.0",
+			},
+		},
+		{
+			name:  "malformed request line",
+			input: `192.0.2.10 - - [16/Dec/2025:18:25:34 +0800] "\x16\x03\x01" 400 157 "-" "-"`,
+			want: dto.Request{
+				Time:   reqTime,
+				Client: netip.MustParseAddr("192.0.2.10"),
+				Status: 400,
+				Sent:   157,
+				Agent:  "-",
+			},
+		},
+		{
+			name:     "extended",
+			extended: true,
+			input:    `192.0.2.10 - - [16/Dec/2025:18:25:34 +0800] "GET / HTTP/1.1" 200 612 "-" "Mozilla/5.0 (X11; Linux x86_64)" 0.005 example.com`,
+			want: dto.Request{
+				Time:     reqTime,
+				Client:   netip.MustParseAddr("192.0.2.10"),
+				Method:   "GET",
+				URL:      "/",
+				Status:   200,
+				Sent:     612,
+				Duration: 5 * time.Millisecond,
+				Host:     "example.com",
+				Agent:    "Mozilla/5.0 (X11; Linux x86_64)",
+			},
+		},
+		{
+			name:     "extended fields missing",
+			extended: true,
+			input:    `192.0.2.10 - - [16/Dec/2025:18:25:34 +0800] "GET / HTTP/1.1" 200 612 "-" "curl/8.5.0"`,
+			wantErr:  true,
+		},
+		{
+			name:    "bad client",
+			input:   `example.com - - [16/Dec/2025:18:25:34 +0800] "GET / HTTP/1.1" 200 612 "-" "curl/8.5.0"`,
+			wantErr: true,
+		},
+		{
+			name:    "unterminated quote",
+			input:   `192.0.2.10 - - [16/Dec/2025:18:25:34 +0800] "GET / HTTP/1.1`,
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := &NginxCombinedParser{extended: tt.extended}
+			got, err := p.Parse([]byte(tt.input))
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Parse() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if tt.wantErr {
+				return
+			}
+			if !got.Time.Equal(tt.want.Time) {
+				t.Errorf("Parse() time = %v, want %v", got.Time, tt.want.Time)
+			}
+			got.Time = tt.want.Time
+			if got != tt.want {
+				t.Errorf("Parse() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
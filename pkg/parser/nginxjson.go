@@ -27,9 +27,16 @@ type nginxJSONLogEntry struct {
 	Duration string `json:"duration"`
 	Host     string `json:"host"`
 	Agent    string `json:"agent"`
+
+	// Optional forwarding fields, see ProxyOptions
+	ForwardedFor  string `json:"forwarded_for"`  // $http_x_forwarded_for
+	RealIP        string `json:"real_ip"`        // $http_x_real_ip
+	ProxyProtocol string `json:"proxy_protocol"` // $proxy_protocol_addr
 }
 
-type NginxJSONParser struct{}
+type NginxJSONParser struct {
+	proxy clientResolver
+}
 
 func (p *NginxJSONParser) Parse(line []byte) (dto.Request, error) {
 	var logEntry nginxJSONLogEntry
@@ -52,6 +59,11 @@ func (p *NginxJSONParser) Parse(line []byte) (dto.Request, error) {
 	if err != nil {
 		return dto.Request{}, err
 	}
+	r.Client = p.proxy.resolve(r.Client, forwarded{
+		ForwardedFor:  logEntry.ForwardedFor,
+		RealIP:        logEntry.RealIP,
+		ProxyProtocol: logEntry.ProxyProtocol,
+	})
 	r.Server, err = netip.ParseAddr(logEntry.Server)
 	if err != nil {
 		return dto.Request{}, err
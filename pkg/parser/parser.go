@@ -1,19 +1,63 @@
 package parser
 
 import (
+	"errors"
 	"fmt"
 
 	"github.com/HT4w5/nyaago/pkg/dto"
 )
 
+// Returned for lines that carry no request, like W3C directives. Not a parse failure
+var ErrSkipLine = errors.New("line carries no request")
+
 type Parser interface {
 	Parse(line []byte) (dto.Request, error)
 }
 
-func MakeParser(logFormat string) (Parser, error) {
+// Parser keeping state per sender, like the field order of W3C logs.
+// Ingresses that know the sender of a line use ParseFrom
+type OriginParser interface {
+	Parser
+	ParseFrom(origin string, line []byte) (dto.Request, error)
+}
+
+// Options for configurable log formats
+type Options struct {
+	Regex RegexOptions
+	JSON  JSONOptions
+	Proxy ProxyOptions // Honoured by formats that carry forwarding fields
+}
+
+func MakeParser(logFormat string, opts Options) (Parser, error) {
 	switch logFormat {
 	case "nginxjson":
-		return &NginxJSONParser{}, nil
+		return &NginxJSONParser{proxy: makeClientResolver(opts.Proxy)}, nil
+	case "nginxcombined":
+		return &NginxCombinedParser{}, nil
+	case "nginxcombinedext":
+		return &NginxCombinedParser{extended: true}, nil
+	case "caddy":
+		return &CaddyParser{proxy: makeClientResolver(opts.Proxy)}, nil
+	case "traefik":
+		return &TraefikParser{proxy: makeClientResolver(opts.Proxy)}, nil
+	case "haproxy":
+		return &HAProxyParser{}, nil
+	case "apachecombined":
+		return &ApacheCombinedParser{}, nil
+	case "w3c":
+		return MakeW3CParser(opts.Proxy), nil
+	case "regex":
+		p, err := MakeRegexParser(opts.Regex, opts.Proxy)
+		if err != nil {
+			return nil, err
+		}
+		return p, nil
+	case "json":
+		p, err := MakeJSONParser(opts.JSON, opts.Proxy)
+		if err != nil {
+			return nil, err
+		}
+		return p, nil
 	default:
 		return nil, fmt.Errorf("unsupported log format: %s", logFormat)
 	}
@@ -0,0 +1,85 @@
+package parser
+
+import (
+	"net/netip"
+	"strings"
+)
+
+type ProxyOptions struct {
+	Trusted []netip.Prefix // Proxies whose forwarding fields are believed. Empty disables client extraction
+}
+
+// Forwarding fields captured from a log line. Empty or "-" when absent
+type forwarded struct {
+	ForwardedFor  string // X-Forwarded-For, comma separated, client first
+	RealIP        string // X-Real-IP, used when X-Forwarded-For is absent
+	ProxyProtocol string // Source address from the PROXY protocol header
+}
+
+// Derives the real client from forwarding fields of requests sent by trusted proxies.
+// The zero value trusts nobody and leaves the peer address unchanged
+type clientResolver struct {
+	trusted []netip.Prefix
+}
+
+func makeClientResolver(opts ProxyOptions) clientResolver {
+	return clientResolver{trusted: opts.Trusted}
+}
+
+func (c clientResolver) isTrusted(addr netip.Addr) bool {
+	for _, p := range c.trusted {
+		if p.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// Walk the forwarding chain right-to-left starting at peer and return the first untrusted hop.
+// The chain is X-Forwarded-For (or X-Real-IP), then the PROXY protocol source, then peer.
+// Walking stops at malformed hops, keeping the last valid address
+func (c clientResolver) resolve(peer netip.Addr, f forwarded) netip.Addr {
+	if len(c.trusted) == 0 || !c.isTrusted(peer) {
+		return peer
+	}
+
+	var hops []string
+	switch {
+	case present(f.ForwardedFor):
+		hops = strings.Split(f.ForwardedFor, ",")
+	case present(f.RealIP):
+		hops = []string{f.RealIP}
+	}
+	if present(f.ProxyProtocol) {
+		hops = append(hops, f.ProxyProtocol)
+	}
+
+	client := peer
+	for i := len(hops) - 1; i >= 0; i-- {
+		addr, ok := parseHop(hops[i])
+		if !ok {
+			break
+		}
+		client = addr
+		if !c.isTrusted(addr) {
+			break
+		}
+	}
+	return client
+}
+
+// Parse a forwarding hop, which may carry a port
+func parseHop(s string) (netip.Addr, bool) {
+	s = strings.TrimSpace(s)
+	if addr, err := netip.ParseAddr(s); err == nil {
+		return addr.Unmap(), true
+	}
+	if ap, err := netip.ParseAddrPort(s); err == nil {
+		return ap.Addr().Unmap(), true
+	}
+	return netip.Addr{}, false
+}
+
+func present(s string) bool {
+	return s != "" && s != "-"
+}
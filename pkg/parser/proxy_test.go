@@ -0,0 +1,92 @@
+package parser
+
+import (
+	"net/netip"
+	"testing"
+)
+
+func TestClientResolverResolve(t *testing.T) {
+	c := makeClientResolver(ProxyOptions{
+		Trusted: []netip.Prefix{
+			netip.MustParsePrefix("10.0.0.0/8"),
+			netip.MustParsePrefix("2001:db8:ffff::/48"),
+		},
+	})
+
+	tests := []struct {
+		name string
+		peer string
+		fwd  forwarded
+		want string
+	}{
+		{
+			name: "untrusted peer ignores forwarding fields",
+			peer: "192.0.2.10",
+			fwd:  forwarded{ForwardedFor: "198.51.100.1"},
+			want: "192.0.2.10",
+		},
+		{
+			name: "single hop",
+			peer: "10.0.0.2",
+			fwd:  forwarded{ForwardedFor: "198.51.100.1"},
+			want: "198.51.100.1",
+		},
+		{
+			name: "spoofed leftmost hop is not reached",
+			peer: "10.0.0.2",
+			fwd:  forwarded{ForwardedFor: "203.0.113.66, 198.51.100.1, 10.0.0.3"},
+			want: "198.51.100.1",
+		},
+		{
+			name: "all hops trusted",
+			peer: "10.0.0.2",
+			fwd:  forwarded{ForwardedFor: "10.1.0.1, 10.0.0.3"},
+			want: "10.1.0.1",
+		},
+		{
+			name: "malformed hop keeps last valid address",
+			peer: "10.0.0.2",
+			fwd:  forwarded{ForwardedFor: "unknown, 10.0.0.3"},
+			want: "10.0.0.3",
+		},
+		{
+			name: "hops with ports and mapped addresses",
+			peer: "2001:db8:ffff::1",
+			fwd:  forwarded{ForwardedFor: "[2001:db8::1]:4711, ::ffff:10.0.0.3"},
+			want: "2001:db8::1",
+		},
+		{
+			name: "real ip when forwarded for is absent",
+			peer: "10.0.0.2",
+			fwd:  forwarded{ForwardedFor: "-", RealIP: "198.51.100.1"},
+			want: "198.51.100.1",
+		},
+		{
+			name: "proxy protocol source",
+			peer: "10.0.0.2",
+			fwd:  forwarded{ForwardedFor: "203.0.113.66", ProxyProtocol: "198.51.100.1"},
+			want: "198.51.100.1",
+		},
+		{
+			name: "proxy protocol from trusted balancer",
+			peer: "10.0.0.2",
+			fwd:  forwarded{ForwardedFor: "198.51.100.1", ProxyProtocol: "10.0.0.4"},
+			want: "198.51.100.1",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := c.resolve(netip.MustParseAddr(tt.peer), tt.fwd)
+			if got != netip.MustParseAddr(tt.want) {
+				t.Errorf("resolve() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	var zero clientResolver
+	peer := netip.MustParseAddr("10.0.0.2")
+	if got := zero.resolve(peer, forwarded{ForwardedFor: "198.51.100.1"}); got != peer {
+		t.Errorf("zero resolve() = %v, want %v", got, peer)
+	}
+}
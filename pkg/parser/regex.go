@@ -0,0 +1,158 @@
+package parser
+
+import (
+	"fmt"
+	"net/netip"
+	"regexp"
+	"slices"
+	"strconv"
+
+	"github.com/HT4w5/nyaago/pkg/dto"
+)
+
+const (
+	regexGroupClient   = "client"
+	regexGroupTime     = "time"
+	regexGroupServer   = "server"
+	regexGroupMethod   = "method"
+	regexGroupURL      = "url"
+	regexGroupStatus   = "status"
+	regexGroupSent     = "sent"
+	regexGroupDuration = "duration"
+	regexGroupHost     = "host"
+	regexGroupAgent    = "agent"
+
+	regexGroupForwardedFor  = "forwarded_for"
+	regexGroupRealIP        = "real_ip"
+	regexGroupProxyProtocol = "proxy_protocol"
+)
+
+var regexGroups = []string{
+	regexGroupClient,
+	regexGroupTime,
+	regexGroupServer,
+	regexGroupMethod,
+	regexGroupURL,
+	regexGroupStatus,
+	regexGroupSent,
+	regexGroupDuration,
+	regexGroupHost,
+	regexGroupAgent,
+	regexGroupForwardedFor,
+	regexGroupRealIP,
+	regexGroupProxyProtocol,
+}
+
+type RegexOptions struct {
+	Pattern        string // Named groups map onto dto.Request fields or forwarding fields. client is required
+	TimeLayout     string // See parseTimeLayout. Defaults to RFC 3339
+	DurationLayout string // See parseDurationLayout. Defaults to seconds
+}
+
+// Parser for arbitrary text logs using a regular expression with named capture groups.
+// Unmatched or empty optional groups leave fields at zero value
+type RegexParser struct {
+	opts  RegexOptions
+	re    *regexp.Regexp
+	idx   map[string]int // Group name to submatch index
+	proxy clientResolver
+}
+
+func MakeRegexParser(opts RegexOptions, proxy ProxyOptions) (*RegexParser, error) {
+	re, err := regexp.Compile(opts.Pattern)
+	if err != nil {
+		return nil, fmt.Errorf("bad pattern: %w", err)
+	}
+	err = validateTimeLayout(opts.TimeLayout)
+	if err != nil {
+		return nil, err
+	}
+	err = validateDurationLayout(opts.DurationLayout)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &RegexParser{
+		opts:  opts,
+		re:    re,
+		idx:   make(map[string]int),
+		proxy: makeClientResolver(proxy),
+	}
+	for i, name := range re.SubexpNames() {
+		if name == "" {
+			continue
+		}
+		if !slices.Contains(regexGroups, name) {
+			return nil, fmt.Errorf("unknown capture group: %s", name)
+		}
+		p.idx[name] = i
+	}
+	if _, ok := p.idx[regexGroupClient]; !ok {
+		return nil, fmt.Errorf("pattern must contain capture group %s", regexGroupClient)
+	}
+
+	return p, nil
+}
+
+func (p *RegexParser) Parse(line []byte) (dto.Request, error) {
+	m := p.re.FindSubmatch(line)
+	if m == nil {
+		return dto.Request{}, fmt.Errorf("line does not match pattern")
+	}
+
+	group := func(name string) string {
+		i, ok := p.idx[name]
+		if !ok {
+			return ""
+		}
+		return string(m[i])
+	}
+
+	var r dto.Request
+	var err error
+	r.Client, err = netip.ParseAddr(group(regexGroupClient))
+	if err != nil {
+		return dto.Request{}, err
+	}
+	r.Client = p.proxy.resolve(r.Client, forwarded{
+		ForwardedFor:  group(regexGroupForwardedFor),
+		RealIP:        group(regexGroupRealIP),
+		ProxyProtocol: group(regexGroupProxyProtocol),
+	})
+	if s := group(regexGroupServer); s != "" {
+		r.Server, err = netip.ParseAddr(s)
+		if err != nil {
+			return dto.Request{}, err
+		}
+	}
+	if s := group(regexGroupTime); s != "" {
+		r.Time, err = parseTimeLayout(s, p.opts.TimeLayout)
+		if err != nil {
+			return dto.Request{}, fmt.Errorf("bad time: %w", err)
+		}
+	}
+	r.Method = group(regexGroupMethod)
+	r.URL = group(regexGroupURL)
+	if s := group(regexGroupStatus); s != "" {
+		r.Status, err = strconv.Atoi(s)
+		if err != nil {
+			return dto.Request{}, fmt.Errorf("bad status: %w", err)
+		}
+	}
+	if s := group(regexGroupSent); s != "" && s != "-" {
+		r.Sent, err = strconv.ParseInt(s, 10, 64)
+		if err != nil {
+			return dto.Request{}, fmt.Errorf("bad sent: %w", err)
+		}
+	}
+	if s := group(regexGroupDuration); s != "" && s != "-" {
+		r.Duration, err = parseDurationLayout(s, p.opts.DurationLayout)
+		if err != nil {
+			return dto.Request{}, fmt.Errorf("bad duration: %w", err)
+		}
+	}
+	r.Host = group(regexGroupHost)
+	r.Agent = group(regexGroupAgent)
+
+	return r, nil
+}
@@ -0,0 +1,130 @@
+package parser
+
+import (
+	"net/netip"
+	"testing"
+	"time"
+
+	"github.com/HT4w5/nyaago/pkg/dto"
+)
+
+func TestMakeRegexParser(t *testing.T) {
+	tests := []struct {
+		name    string
+		opts    RegexOptions
+		wantErr bool
+	}{
+		{
+			name: "valid",
+			opts: RegexOptions{Pattern: `^(?P<client>\S+) (?P<url>\S+)$`},
+		},
+		{
+			name:    "missing client group",
+			opts:    RegexOptions{Pattern: `^(?P<url>\S+)$`},
+			wantErr: true,
+		},
+		{
+			name:    "unknown group",
+			opts:    RegexOptions{Pattern: `^(?P<client>\S+) (?P<referer>\S+)$`},
+			wantErr: true,
+		},
+		{
+			name:    "bad pattern",
+			opts:    RegexOptions{Pattern: `^(?P<client>\S+`},
+			wantErr: true,
+		},
+		{
+			name:    "bad duration layout",
+			opts:    RegexOptions{Pattern: `^(?P<client>\S+)$`, DurationLayout: "fortnight"},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := MakeRegexParser(tt.opts, ProxyOptions{})
+			if (err != nil) != tt.wantErr {
+				t.Errorf("MakeRegexParser() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestRegexParse(t *testing.T) {
+	tests := []struct {
+		name    string
+		opts    RegexOptions
+		input   string
+		want    dto.Request
+		wantErr bool
+	}{
+		{
+			name: "appliance log with unix time and milliseconds",
+			opts: RegexOptions{
+				Pattern:        `^(?P<time>\S+) (?P<client>\S+) (?P<method>\S+) (?P<url>\S+) (?P<status>\d+) (?P<sent>\S+) (?P<duration>\d+)ms$`,
+				TimeLayout:     TimeLayoutUnix,
+				DurationLayout: DurationLayoutMs,
+			},
+			input: `1734345934.5 192.0.2.10 GET /firmware.bin 200 1048576 250ms`,
+			want: dto.Request{
+				Time:     time.Unix(1734345934, 500000000),
+				Client:   netip.MustParseAddr("192.0.2.10"),
+				Method:   "GET",
+				URL:      "/firmware.bin",
+				Status:   200,
+				Sent:     1048576,
+				Duration: 250 * time.Millisecond,
+			},
+		},
+		{
+			name: "go time layout and dash sent",
+			opts: RegexOptions{
+				Pattern:    `^\[(?P<time>[^\]]+)\] (?P<client>\S+) (?P<host>\S+) (?P<sent>\S+) "(?P<agent>[^"]*)"$`,
+				TimeLayout: "2006-01-02 15:04:05",
+			},
+			input: `[2025-12-16 10:25:34] 2001:db8::1 example.com - "curl/8.5.0"`,
+			want: dto.Request{
+				Time:   time.Date(2025, time.December, 16, 10, 25, 34, 0, time.UTC),
+				Client: netip.MustParseAddr("2001:db8::1"),
+				Host:   "example.com",
+				Agent:  "curl/8.5.0",
+			},
+		},
+		{
+			name:    "no match",
+			opts:    RegexOptions{Pattern: `^(?P<client>\S+) (?P<url>\S+)$`},
+			input:   `192.0.2.10`,
+			wantErr: true,
+		},
+		{
+			name:    "bad client",
+			opts:    RegexOptions{Pattern: `^(?P<client>\S+) (?P<url>\S+)$`},
+			input:   `example.com /`,
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := MakeRegexParser(tt.opts, ProxyOptions{})
+			if err != nil {
+				t.Fatalf("MakeRegexParser() error = %v", err)
+			}
+			got, err := p.Parse([]byte(tt.input))
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Parse() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if tt.wantErr {
+				return
+			}
+			if !got.Time.Equal(tt.want.Time) {
+				t.Errorf("Parse() time = %v, want %v", got.Time, tt.want.Time)
+			}
+			got.Time = tt.want.Time
+			if got != tt.want {
+				t.Errorf("Parse() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
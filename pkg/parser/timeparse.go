@@ -0,0 +1,115 @@
+package parser
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Special time layouts. Anything else is a Go reference layout
+const (
+	TimeLayoutUnix    = "unix"    // Seconds, optionally fractional
+	TimeLayoutUnixMs  = "unix_ms" // Milliseconds
+	TimeLayoutRFC3339 = "rfc3339"
+)
+
+// Duration layouts
+const (
+	DurationLayoutSeconds = "s"  // Seconds, optionally fractional, like $request_time
+	DurationLayoutMs      = "ms" // Milliseconds
+	DurationLayoutUs      = "us" // Microseconds, like Apache's %D
+	DurationLayoutGo      = "go" // Go duration string, e.g. "1.5ms"
+)
+
+func parseTimeLayout(s string, layout string) (time.Time, error) {
+	switch layout {
+	case TimeLayoutUnix:
+		return parseUnix(s, nsPerS)
+	case TimeLayoutUnixMs:
+		return parseUnix(s, nsPerS/1000)
+	case TimeLayoutRFC3339, "":
+		return time.Parse(time.RFC3339Nano, s)
+	default:
+		return time.Parse(layout, s)
+	}
+}
+
+func parseDurationLayout(s string, layout string) (time.Duration, error) {
+	if layout == DurationLayoutGo {
+		return time.ParseDuration(s)
+	}
+
+	var unit float64
+	switch layout {
+	case DurationLayoutSeconds, "":
+		unit = nsPerS
+	case DurationLayoutMs:
+		unit = nsPerS / 1000
+	case DurationLayoutUs:
+		unit = nsPerS / 1000000
+	default:
+		return 0, fmt.Errorf("unsupported duration layout: %s", layout)
+	}
+
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return 0, err
+	}
+	return time.Duration(math.Round(f * unit)), nil
+}
+
+// Parse a decimal unix timestamp in units of unitNs nanoseconds without losing precision.
+// unitNs must divide a second
+func parseUnix(s string, unitNs int64) (time.Time, error) {
+	digits, neg := strings.CutPrefix(s, "-")
+	intStr, fStr, _ := strings.Cut(digits, ".")
+	// Sub-nanosecond digits are dropped
+	if len(fStr) > 9 {
+		fStr = fStr[:9]
+	}
+	i, err := strconv.ParseUint(intStr, 10, 63)
+	var f uint64
+	if err == nil && fStr != "" {
+		f, err = strconv.ParseUint(fStr, 10, 64)
+	}
+	if err != nil {
+		// Exponent notation and other forms only a float parser accepts
+		v, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return time.Time{}, err
+		}
+		return time.Unix(0, 0).Add(time.Duration(math.Round(v * float64(unitNs)))), nil
+	}
+
+	perS := nsPerS / unitNs
+	sec := int64(i) / perS
+	nsec := int64(i)%perS*unitNs + int64(f)*unitNs/int64(math.Pow10(len(fStr)))
+	if neg {
+		sec, nsec = -sec, -nsec
+	}
+	return time.Unix(sec, nsec), nil
+}
+
+func validateTimeLayout(layout string) error {
+	switch layout {
+	case TimeLayoutUnix, TimeLayoutUnixMs, TimeLayoutRFC3339, "":
+		return nil
+	}
+	// Go layouts must at least format to something parseable
+	_, err := time.Parse(layout, time.Unix(0, 0).UTC().Format(layout))
+	if err != nil {
+		return fmt.Errorf("bad time layout %s: %w", layout, err)
+	}
+	return nil
+}
+
+func validateDurationLayout(layout string) error {
+	switch layout {
+	case DurationLayoutSeconds, DurationLayoutMs, DurationLayoutUs, DurationLayoutGo, "":
+		return nil
+	default:
+		return fmt.Errorf("unsupported duration layout: %s", layout)
+	}
+}
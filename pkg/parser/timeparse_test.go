@@ -0,0 +1,77 @@
+package parser
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseUnix(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		unitNs   int64
+		wantTime time.Time
+		wantErr  bool
+	}{
+		{
+			name:     "seconds",
+			input:    "1734345934",
+			unitNs:   nsPerS,
+			wantTime: time.Unix(1734345934, 0),
+		},
+		{
+			name:     "fractional seconds",
+			input:    "1734345934.123456789",
+			unitNs:   nsPerS,
+			wantTime: time.Unix(1734345934, 123456789),
+		},
+		{
+			name:     "sub-nanosecond digits",
+			input:    "1734345934.1234567891234567891",
+			unitNs:   nsPerS,
+			wantTime: time.Unix(1734345934, 123456789),
+		},
+		{
+			name:     "negative fractional seconds",
+			input:    "-1.5",
+			unitNs:   nsPerS,
+			wantTime: time.Unix(-1, -500000000),
+		},
+		{
+			name:     "exponent notation",
+			input:    "1.7e9",
+			unitNs:   nsPerS,
+			wantTime: time.Unix(1700000000, 0),
+		},
+		{
+			name:     "fractional milliseconds",
+			input:    "1734345934123.5",
+			unitNs:   nsPerS / 1000,
+			wantTime: time.Unix(1734345934, 123500000),
+		},
+		{
+			name:     "sub-nanosecond milliseconds",
+			input:    "1734345934123.4567891234",
+			unitNs:   nsPerS / 1000,
+			wantTime: time.Unix(1734345934, 123456789),
+		},
+		{
+			name:    "invalid",
+			input:   "12:00",
+			unitNs:  nsPerS,
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseUnix(tt.input, tt.unitNs)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseUnix() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !got.Equal(tt.wantTime) {
+				t.Errorf("parseUnix() = %v, want %v", got, tt.wantTime)
+			}
+		})
+	}
+}
@@ -0,0 +1,62 @@
+package parser
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/netip"
+	"time"
+
+	"github.com/HT4w5/nyaago/pkg/dto"
+)
+
+type traefikLogEntry struct {
+	ClientHost            string `json:"ClientHost"`
+	StartUTC              string `json:"StartUTC"` // RFC 3339
+	RequestMethod         string `json:"RequestMethod"`
+	RequestPath           string `json:"RequestPath"`
+	RequestHost           string `json:"RequestHost"`
+	DownstreamStatus      int    `json:"DownstreamStatus"`
+	DownstreamContentSize int64  `json:"DownstreamContentSize"`
+	Duration              int64  `json:"Duration"` // Nanoseconds
+	UserAgent             string `json:"request_User-Agent"`
+	ForwardedFor          string `json:"request_X-Forwarded-For"` // Requires accessLog.fields.headers to keep it
+	RealIP                string `json:"request_X-Real-Ip"`
+}
+
+// Parser for Traefik's JSON access log
+type TraefikParser struct {
+	proxy clientResolver
+}
+
+func (p *TraefikParser) Parse(line []byte) (dto.Request, error) {
+	var logEntry traefikLogEntry
+	err := json.Unmarshal(line, &logEntry)
+	if err != nil {
+		return dto.Request{}, err
+	}
+
+	r := dto.Request{
+		Method:   logEntry.RequestMethod,
+		URL:      stripQuery(logEntry.RequestPath),
+		Status:   logEntry.DownstreamStatus,
+		Sent:     logEntry.DownstreamContentSize,
+		Duration: time.Duration(logEntry.Duration),
+		Host:     logEntry.RequestHost,
+		Agent:    logEntry.UserAgent,
+	}
+
+	r.Client, err = netip.ParseAddr(logEntry.ClientHost)
+	if err != nil {
+		return dto.Request{}, err
+	}
+	r.Client = p.proxy.resolve(r.Client, forwarded{
+		ForwardedFor: logEntry.ForwardedFor,
+		RealIP:       logEntry.RealIP,
+	})
+	r.Time, err = time.Parse(time.RFC3339Nano, logEntry.StartUTC)
+	if err != nil {
+		return dto.Request{}, fmt.Errorf("bad StartUTC: %w", err)
+	}
+
+	return r, nil
+}
@@ -0,0 +1,65 @@
+package parser
+
+import (
+	"net/netip"
+	"testing"
+	"time"
+
+	"github.com/HT4w5/nyaago/pkg/dto"
+)
+
+func TestTraefikParse(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		want    dto.Request
+		wantErr bool
+	}{
+		{
+			name:  "access log",
+			input: `{"ClientAddr":"192.0.2.10:52918","ClientHost":"192.0.2.10","ClientPort":"52918","ClientUsername":"-","DownstreamContentSize":4096,"DownstreamStatus":200,"Duration":2534000,"OriginContentSize":4096,"OriginDuration":2300000,"OriginStatus":200,"Overhead":234000,"RequestAddr":"example.com","RequestContentSize":0,"RequestCount":12,"RequestHost":"example.com","RequestMethod":"GET","RequestPath":"/downloads/debian.iso?mirror=1","RequestPort":"-","RequestProtocol":"HTTP/1.1","RequestScheme":"https","RetryAttempts":0,"RouterName":"web@docker","ServiceAddr":"10.0.0.5:80","ServiceName":"web@docker","StartLocal":"2024-12-16T10:45:34.123456789Z","StartUTC":"2024-12-16T10:45:34.123456789Z","entryPointName":"websecure","level":"info","msg":"","request_User-Agent":"Wget/1.21.4","time":"2024-12-16T10:45:34Z"}`,
+			want: dto.Request{
+				Time:     time.Date(2024, time.December, 16, 10, 45, 34, 123456789, time.UTC),
+				Client:   netip.MustParseAddr("192.0.2.10"),
+				Method:   "GET",
+				URL:      "/downloads/debian.iso",
+				Status:   200,
+				Sent:     4096,
+				Duration: 2534 * time.Microsecond,
+				Host:     "example.com",
+				Agent:    "Wget/1.21.4",
+			},
+		},
+		{
+			name:    "bad start time",
+			input:   `{"ClientHost":"192.0.2.10","StartUTC":"yesterday","DownstreamStatus":200}`,
+			wantErr: true,
+		},
+		{
+			name:    "missing client host",
+			input:   `{"StartUTC":"2024-12-16T10:45:34Z","DownstreamStatus":200}`,
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := &TraefikParser{}
+			got, err := p.Parse([]byte(tt.input))
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Parse() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if tt.wantErr {
+				return
+			}
+			if !got.Time.Equal(tt.want.Time) {
+				t.Errorf("Parse() time = %v, want %v", got.Time, tt.want.Time)
+			}
+			got.Time = tt.want.Time
+			if got != tt.want {
+				t.Errorf("Parse() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
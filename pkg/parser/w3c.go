@@ -0,0 +1,194 @@
+package parser
+
+import (
+	"fmt"
+	"net/netip"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/HT4w5/nyaago/pkg/dto"
+)
+
+const (
+	w3cDirectivePrefix = "#"
+	w3cFieldsDirective = "#Fields:"
+
+	w3cDateTimeLayout = "2006-01-02 15:04:05"
+)
+
+// IIS default field set, used until a #Fields directive is seen
+var w3cDefaultFields = []string{
+	"date", "time", "s-ip", "cs-method", "cs-uri-stem", "cs-uri-query", "s-port", "cs-username", "c-ip",
+	"cs(User-Agent)", "cs(Referer)", "sc-status", "sc-substatus", "sc-win32-status", "time-taken",
+}
+
+// Origins whose #Fields directive is remembered. Beyond this an arbitrary origin is
+// forgotten and falls back to the default fields until it sends #Fields again
+const maxW3COrigins = 1024
+
+var w3cDefaultFieldIndex = makeW3CFieldIndex(w3cDefaultFields)
+
+// Parser for the W3C extended log format as written by IIS.
+// Field order follows the most recent #Fields directive of the same origin, so
+// senders sharing a source do not disturb each other. Other directives are skipped.
+// Date and time are UTC and time-taken is in milliseconds
+type W3CParser struct {
+	mu     sync.RWMutex
+	fields map[string]map[string]int // Keyed by origin
+	proxy  clientResolver
+}
+
+func MakeW3CParser(proxy ProxyOptions) *W3CParser {
+	return &W3CParser{
+		fields: make(map[string]map[string]int),
+		proxy:  makeClientResolver(proxy),
+	}
+}
+
+// Parse a line of an unknown origin
+func (p *W3CParser) Parse(line []byte) (dto.Request, error) {
+	return p.ParseFrom("", line)
+}
+
+func (p *W3CParser) ParseFrom(origin string, line []byte) (dto.Request, error) {
+	s := strings.TrimRight(string(line), "\r\n")
+	if strings.HasPrefix(s, w3cDirectivePrefix) {
+		if rest, ok := strings.CutPrefix(s, w3cFieldsDirective); ok {
+			names := strings.Fields(rest)
+			if len(names) == 0 {
+				return dto.Request{}, fmt.Errorf("empty #Fields directive")
+			}
+			p.setFields(origin, names)
+		}
+		return dto.Request{}, ErrSkipLine
+	}
+
+	values, err := splitW3CFields(s)
+	if err != nil {
+		return dto.Request{}, err
+	}
+
+	p.mu.RLock()
+	fields, ok := p.fields[origin]
+	p.mu.RUnlock()
+	if !ok {
+		fields = w3cDefaultFieldIndex
+	}
+
+	get := func(name string) string {
+		idx, ok := fields[name]
+		if !ok || idx >= len(values) || values[idx] == "-" {
+			return ""
+		}
+		return values[idx]
+	}
+
+	var r dto.Request
+	r.Client, err = netip.ParseAddr(get("c-ip"))
+	if err != nil {
+		return dto.Request{}, fmt.Errorf("bad client address: %w", err)
+	}
+	r.Client = p.proxy.resolve(r.Client, forwarded{
+		ForwardedFor: get("cs(X-Forwarded-For)"),
+		RealIP:       get("cs(X-Real-IP)"),
+	})
+	r.Time, err = time.Parse(w3cDateTimeLayout, get("date")+" "+get("time"))
+	if err != nil {
+		return dto.Request{}, fmt.Errorf("bad date or time: %w", err)
+	}
+	r.Method = get("cs-method")
+	if uri := get("cs-uri-stem"); uri != "" {
+		r.URL = stripQuery(uri)
+	} else {
+		r.URL = stripQuery(get("cs-uri"))
+	}
+	if v := get("sc-status"); v != "" {
+		r.Status, err = strconv.Atoi(v)
+		if err != nil {
+			return dto.Request{}, fmt.Errorf("bad status: %w", err)
+		}
+	}
+	if v := get("sc-bytes"); v != "" {
+		r.Sent, err = strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			return dto.Request{}, fmt.Errorf("bad bytes sent: %w", err)
+		}
+	}
+	if v := get("time-taken"); v != "" {
+		r.Duration, err = parseDurationLayout(v, DurationLayoutMs)
+		if err != nil {
+			return dto.Request{}, fmt.Errorf("bad time taken: %w", err)
+		}
+	}
+	r.Host = get("cs-host")
+	if r.Host == "" {
+		r.Host = get("cs(Host)")
+	}
+	// IIS encodes spaces in header values as '+'
+	r.Agent = strings.ReplaceAll(get("cs(User-Agent)"), "+", " ")
+
+	return r, nil
+}
+
+func (p *W3CParser) setFields(origin string, names []string) {
+	fields := makeW3CFieldIndex(names)
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if _, ok := p.fields[origin]; !ok && len(p.fields) >= maxW3COrigins {
+		for k := range p.fields {
+			delete(p.fields, k)
+			break
+		}
+	}
+	p.fields[origin] = fields
+}
+
+func makeW3CFieldIndex(names []string) map[string]int {
+	fields := make(map[string]int, len(names))
+	for i, name := range names {
+		fields[name] = i
+	}
+	return fields
+}
+
+// Split a W3C log entry on whitespace. Values may be enclosed in "" with "" as an escaped quote
+func splitW3CFields(line string) ([]string, error) {
+	values := make([]string, 0, 16)
+	i := 0
+	for i < len(line) {
+		switch line[i] {
+		case ' ', '\t':
+			i++
+		case '"':
+			var b strings.Builder
+			end := i + 1
+			for {
+				if end >= len(line) {
+					return nil, fmt.Errorf("unterminated quote at %d", i)
+				}
+				if line[end] == '"' {
+					if end+1 < len(line) && line[end+1] == '"' {
+						b.WriteByte('"')
+						end += 2
+						continue
+					}
+					break
+				}
+				b.WriteByte(line[end])
+				end++
+			}
+			values = append(values, b.String())
+			i = end + 1
+		default:
+			end := strings.IndexAny(line[i:], " \t")
+			if end < 0 {
+				end = len(line) - i
+			}
+			values = append(values, line[i:i+end])
+			i += end
+		}
+	}
+	return values, nil
+}
@@ -0,0 +1,127 @@
+package parser
+
+import (
+	"errors"
+	"net/netip"
+	"testing"
+	"time"
+
+	"github.com/HT4w5/nyaago/pkg/dto"
+)
+
+func TestW3CParse(t *testing.T) {
+	reqTime := time.Date(2025, time.December, 16, 10, 25, 34, 0, time.UTC)
+
+	// Lines are fed in order through one parser, as they appear in a log file
+	tests := []struct {
+		name    string
+		input   string
+		want    dto.Request
+		skip    bool
+		wantErr bool
+	}{
+		{
+			name:  "default iis fields",
+			input: `2025-12-16 10:25:34 10.0.0.5 GET /files/setup.exe - 443 - 192.0.2.10 Mozilla/5.0+(Windows+NT+10.0) - 200 0 0 1234`,
+			want: dto.Request{
+				Time:     reqTime,
+				Client:   netip.MustParseAddr("192.0.2.10"),
+				Method:   "GET",
+				URL:      "/files/setup.exe",
+				Status:   200,
+				Duration: 1234 * time.Millisecond,
+				Agent:    "Mozilla/5.0 (Windows NT 10.0)",
+			},
+		},
+		{
+			name:  "software directive",
+			input: `#Software: Microsoft Internet Information Services 10.0`,
+			skip:  true,
+		},
+		{
+			name:  "fields directive",
+			input: `#Fields: date time c-ip cs-method cs-uri-stem sc-status sc-bytes time-taken cs-host cs(User-Agent)`,
+			skip:  true,
+		},
+		{
+			name:  "custom fields",
+			input: `2025-12-16 10:25:34 2001:db8::1 GET /a%20b 206 1048576 15 files.example.com "Wget/1.21.4 ""quoted"""`,
+			want: dto.Request{
+				Time:     reqTime,
+				Client:   netip.MustParseAddr("2001:db8::1"),
+				Method:   "GET",
+				URL:      "/a b",
+				Status:   206,
+				Sent:     1048576,
+				Duration: 15 * time.Millisecond,
+				Host:     "files.example.com",
+				Agent:    `Wget/1.21.4 "quoted"`,
+			},
+		},
+		{
+			name:    "bad client address",
+			input:   `2025-12-16 10:25:34 - GET / 200 0 0 - -`,
+			wantErr: true,
+		},
+	}
+
+	p := MakeW3CParser(ProxyOptions{})
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := p.Parse([]byte(tt.input))
+			if tt.skip {
+				if !errors.Is(err, ErrSkipLine) {
+					t.Errorf("Parse() error = %v, want ErrSkipLine", err)
+				}
+				return
+			}
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Parse() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if tt.wantErr {
+				return
+			}
+			if !got.Time.Equal(tt.want.Time) {
+				t.Errorf("Parse() time = %v, want %v", got.Time, tt.want.Time)
+			}
+			got.Time = tt.want.Time
+			if got != tt.want {
+				t.Errorf("Parse() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestW3CParseFromOrigins(t *testing.T) {
+	p := MakeW3CParser(ProxyOptions{})
+	lines := []struct {
+		origin     string
+		input      string
+		wantClient string
+	}{
+		{"a", `#Fields: date time c-ip cs-method cs-uri-stem sc-status`, ""},
+		{"b", `#Fields: date time cs-method cs-uri-stem sc-status c-ip`, ""},
+		{"a", `2025-12-16 10:25:34 192.0.2.10 GET / 200`, "192.0.2.10"},
+		{"b", `2025-12-16 10:25:34 GET / 200 192.0.2.20`, "192.0.2.20"},
+		// Unknown origins use the IIS default fields
+		{"c", `2025-12-16 10:25:34 10.0.0.5 GET / - 443 - 192.0.2.30 - - 200 0 0 1`, "192.0.2.30"},
+	}
+
+	for _, l := range lines {
+		got, err := p.ParseFrom(l.origin, []byte(l.input))
+		if l.wantClient == "" {
+			if !errors.Is(err, ErrSkipLine) {
+				t.Errorf("ParseFrom(%s) error = %v, want ErrSkipLine", l.origin, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("ParseFrom(%s) error = %v", l.origin, err)
+			continue
+		}
+		if got.Client != netip.MustParseAddr(l.wantClient) {
+			t.Errorf("ParseFrom(%s) client = %s, want %s", l.origin, got.Client, l.wantClient)
+		}
+	}
+}